@@ -0,0 +1,198 @@
+package bedrock
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAPIKeyHeader is the header RequireAPIKey reads keys from by default.
+const DefaultAPIKeyHeader = "X-API-Key"
+
+const apiKeyKey contextKey = "apiKey"
+
+// ErrAPIKeyNotFound is returned by an APIKeyStore for unknown prefixes.
+var ErrAPIKeyNotFound = errors.New("bedrock: API key not found")
+
+// APIKey is an API key as kept by an APIKeyStore. The key itself is never
+// stored: only its prefix, which identifies it, and its hash.
+type APIKey struct {
+	Prefix    string    // public part of the key, e.g. "bk_7GQ2M4XK3Z5A"
+	Hash      string    // hex SHA-256 of the full key
+	OwnerID   string    // user or service the key acts as; set as the user ID
+	Name      string    // optional; a label such as "CI deploys"
+	Scopes    []string  // optional; checked by RequireScope
+	ExpiresAt time.Time // optional; zero for keys that never expire
+}
+
+// APIKeyStore looks up API keys by prefix.
+type APIKeyStore interface {
+	// LookupAPIKey returns the key with prefix, or ErrAPIKeyNotFound.
+	LookupAPIKey(ctx context.Context, prefix string) (APIKey, error)
+}
+
+// APIKeyConfig configures RequireAPIKey.
+type APIKeyConfig struct {
+	Store      APIKeyStore
+	Header     string // optional; defaults to DefaultAPIKeyHeader
+	QueryParam string // optional; if set, keys are also accepted in this query parameter
+}
+
+// GenerateAPIKey creates a new API key in the given namespace, e.g. "bk"
+// or "sk_live". It returns the key, to show to its owner once, and the
+// APIKey to store after setting its OwnerID, Scopes and expiry.
+//
+// Keys look like "bk_7GQ2M4XK3Z5A_MZQ3UOWXJ5PYI7NQ4CSR2LKN2E": the
+// namespace and a random ID form the prefix, followed by 128 random bits.
+//
+// Example:
+//
+//	key, apiKey, err := bedrock.GenerateAPIKey("bk")
+//	apiKey.OwnerID = service.ID
+//	apiKey.Scopes = []string{"bookings:read"}
+//	err = a.db.SaveAPIKey(ctx, apiKey)
+//	// return key to the caller; it cannot be recovered later
+func GenerateAPIKey(namespace string) (string, APIKey, error) {
+	if namespace == "" || strings.ContainsAny(namespace, " \t") {
+		return "", APIKey{}, errors.New("bedrock: invalid API key namespace")
+	}
+	prefix := namespace + "_" + rand.Text()[:12]
+	key := prefix + "_" + rand.Text()
+	return key, APIKey{Prefix: prefix, Hash: hashToken(key)}, nil
+}
+
+// RequireAPIKey creates middleware that authenticates requests with an API
+// key sent in a header (X-API-Key by default) or, if configured, a query
+// parameter.
+//
+// If the key is valid and not expired, its owner is added to the context
+// with WithUserID, so handlers and RequireRole, RequireScope and
+// RequirePermission work as with RequireAuth; the key's scopes are
+// available to RequireScope. Otherwise it returns a 401.
+//
+// Usage:
+//
+//	apiKeys := bedrock.RequireAPIKey(bedrock.APIKeyConfig{Store: a.keyStore})
+//	routes := []bedrock.Route{
+//	    {
+//	        Method:     "GET",
+//	        Path:       "/v1/bookings",
+//	        Handler:    a.listBookings,
+//	        Middleware: []bedrock.Middleware{apiKeys, bedrock.RequireScope("bookings:read")},
+//	    },
+//	}
+func RequireAPIKey(cfg APIKeyConfig) Middleware {
+	header := cfg.Header
+	if header == "" {
+		header = DefaultAPIKeyHeader
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, r *http.Request) Response {
+			key := r.Header.Get(header)
+			if key == "" && cfg.QueryParam != "" {
+				key = r.URL.Query().Get(cfg.QueryParam)
+			}
+			if key == "" {
+				return Unauthorized("missing API key")
+			}
+
+			apiKey, err := verifyAPIKey(ctx, cfg.Store, key)
+			if errors.Is(err, ErrAPIKeyNotFound) {
+				return Unauthorized("invalid API key")
+			}
+			if err != nil {
+				return InternalError().Wrap(err)
+			}
+
+			// The scope claim lets ClaimsAuthorizer check the key's scopes
+			claims, err := json.Marshal(map[string]any{
+				"sub":   apiKey.OwnerID,
+				"scope": strings.Join(apiKey.Scopes, " "),
+			})
+			if err != nil {
+				return InternalError().Wrap(err)
+			}
+
+			ctx = WithUserID(ctx, apiKey.OwnerID)
+			ctx = withClaims(ctx, claims)
+			ctx = context.WithValue(ctx, apiKeyKey, apiKey)
+			return next(ctx, r)
+		}
+	}
+}
+
+// verifyAPIKey looks up key by its prefix and checks its hash and expiry.
+// Unknown, mismatched and expired keys all return ErrAPIKeyNotFound.
+func verifyAPIKey(ctx context.Context, store APIKeyStore, key string) (APIKey, error) {
+	i := strings.LastIndexByte(key, '_')
+	if i <= 0 {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+
+	apiKey, err := store.LookupAPIKey(ctx, key[:i])
+	if err != nil {
+		return APIKey{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(key)), []byte(apiKey.Hash)) != 1 {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	if !apiKey.ExpiresAt.IsZero() && time.Now().After(apiKey.ExpiresAt) {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return apiKey, nil
+}
+
+// GetAPIKey returns the API key that authenticated the request, if
+// RequireAPIKey did.
+func GetAPIKey(ctx context.Context) (APIKey, bool) {
+	apiKey, ok := ctx.Value(apiKeyKey).(APIKey)
+	return apiKey, ok
+}
+
+// MemoryAPIKeyStore is an in-memory APIKeyStore, for tests and keys
+// loaded from configuration at startup.
+type MemoryAPIKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]APIKey
+}
+
+// NewMemoryAPIKeyStore returns a MemoryAPIKeyStore holding keys.
+func NewMemoryAPIKeyStore(keys ...APIKey) *MemoryAPIKeyStore {
+	s := &MemoryAPIKeyStore{keys: make(map[string]APIKey, len(keys))}
+	for _, key := range keys {
+		s.Add(key)
+	}
+	return s
+}
+
+// Add adds or replaces a key.
+func (s *MemoryAPIKeyStore) Add(key APIKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.Prefix] = key
+}
+
+// Remove revokes the key with prefix.
+func (s *MemoryAPIKeyStore) Remove(prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, prefix)
+}
+
+// LookupAPIKey implements APIKeyStore.
+func (s *MemoryAPIKeyStore) LookupAPIKey(ctx context.Context, prefix string) (APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[prefix]
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
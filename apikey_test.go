@@ -0,0 +1,137 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, apiKey, err := GenerateAPIKey("sk_live")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	if !strings.HasPrefix(key, apiKey.Prefix+"_") || !strings.HasPrefix(apiKey.Prefix, "sk_live_") {
+		t.Errorf("key %q does not start with prefix %q", key, apiKey.Prefix)
+	}
+	if strings.Contains(apiKey.Hash, key[len(apiKey.Prefix)+1:]) {
+		t.Error("secret part stored in plain text")
+	}
+
+	other, _, _ := GenerateAPIKey("sk_live")
+	if other == key {
+		t.Error("expected unique keys")
+	}
+	if _, _, err := GenerateAPIKey(""); err == nil {
+		t.Error("expected error for empty namespace")
+	}
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	valid, validKey, _ := GenerateAPIKey("bk")
+	validKey.OwnerID, validKey.Scopes = "svc-billing", []string{"bookings:read"}
+	expired, expiredKey, _ := GenerateAPIKey("bk")
+	expiredKey.OwnerID, expiredKey.ExpiresAt = "svc-old", time.Now().Add(-time.Hour)
+	store := NewMemoryAPIKeyStore(validKey, expiredKey)
+
+	var gotUserID string
+	var gotKey APIKey
+	handler := Chain(func(ctx context.Context, r *http.Request) Response {
+		gotUserID, _ = GetUserID(ctx)
+		gotKey, _ = GetAPIKey(ctx)
+		return JSON(200, nil)
+	}, RequireAPIKey(APIKeyConfig{Store: store, QueryParam: "api_key"}))
+
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		want   int
+	}{
+		{"header", valid, "", 200},
+		{"query parameter", "", valid, 200},
+		{"missing", "", "", 401},
+		{"expired", expired, "", 401},
+		{"wrong secret", validKey.Prefix + "_WRONGSECRETWRONGSECRETWRO", "", 401},
+		{"unknown prefix", "bk_UNKNOWN_" + valid[len(validKey.Prefix)+1:], "", 401},
+		{"malformed", "nounderscores", "", 401},
+	}
+	for _, tt := range tests {
+		gotUserID = ""
+		req := httptest.NewRequest("GET", "/v1/bookings?api_key="+tt.query, nil)
+		if tt.header != "" {
+			req.Header.Set(DefaultAPIKeyHeader, tt.header)
+		}
+		w := httptest.NewRecorder()
+		handler(context.Background(), req).Write(context.Background(), w)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, w.Code)
+		}
+		if tt.want == 200 && (gotUserID != "svc-billing" || gotKey.Prefix != validKey.Prefix) {
+			t.Errorf("%s: expected owner and key in context, got %q %+v", tt.name, gotUserID, gotKey)
+		}
+	}
+
+	store.Remove(validKey.Prefix)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(DefaultAPIKeyHeader, valid)
+	if resp := handler(context.Background(), req).(*HTTPError); resp.Status != 401 {
+		t.Errorf("expected 401 for a removed key, got %d", resp.Status)
+	}
+}
+
+func TestRequireAPIKey_Scopes(t *testing.T) {
+	key, apiKey, _ := GenerateAPIKey("bk")
+	apiKey.OwnerID, apiKey.Scopes = "svc-reports", []string{"bookings:read", "reports:read"}
+	store := NewMemoryAPIKeyStore(apiKey)
+	auth := RequireAPIKey(APIKeyConfig{Store: store, Header: "Api-Key"})
+
+	ok := func(ctx context.Context, r *http.Request) Response { return JSON(200, nil) }
+	request := func(scope string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Api-Key", key)
+		w := httptest.NewRecorder()
+		Chain(ok, auth, RequireScope(scope))(context.Background(), req).Write(context.Background(), w)
+		return w.Code
+	}
+
+	if code := request("reports:read"); code != 200 {
+		t.Errorf("expected 200 for granted scope, got %d", code)
+	}
+	if code := request("bookings:write"); code != 403 {
+		t.Errorf("expected 403 for missing scope, got %d", code)
+	}
+}
+
+// failingKeyStore is an APIKeyStore whose backend is down.
+type failingKeyStore struct{}
+
+func (failingKeyStore) LookupAPIKey(ctx context.Context, prefix string) (APIKey, error) {
+	return APIKey{}, errors.New("connection refused")
+}
+
+func TestRequireAPIKey_StoreError(t *testing.T) {
+	key, _, _ := GenerateAPIKey("bk")
+	handler := Chain(func(ctx context.Context, r *http.Request) Response {
+		return JSON(200, nil)
+	}, RequireAPIKey(APIKeyConfig{Store: failingKeyStore{}}))
+
+	var buf bytes.Buffer
+	ctx := WithLogger(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(DefaultAPIKeyHeader, key)
+	w := httptest.NewRecorder()
+	handler(ctx, req).Write(ctx, w)
+	if w.Code != 500 {
+		t.Errorf("expected 500 when the store fails, got %d", w.Code)
+	}
+	if !strings.Contains(buf.String(), `"err":"connection refused"`) {
+		t.Errorf("expected the store error to be logged:\n%s", buf.String())
+	}
+}
@@ -19,7 +19,8 @@ const userIDKey contextKey = "userID"
 // It expects the header format: "Authorization: Bearer <token>"
 //
 // If the token is valid, the user ID is added to the request context.
-// If the token is invalid or missing, it returns a 401 Unauthorized response.
+// If the token is invalid, missing or revoked (see Options.Revoker), it
+// returns a 401 Unauthorized response.
 //
 // Usage:
 //
@@ -32,36 +33,71 @@ const userIDKey contextKey = "userID"
 //	    },
 //	}
 func RequireAuth(secret string) Middleware {
+	return RequireAuthWithConfig(TokenConfig{Secret: secret})
+}
+
+// RequireAuthWithKeys is like RequireAuth, but verifies asymmetrically
+// signed tokens (RS256, ES256, ES384, ES512 or EdDSA) with the key named
+// by their kid header. keys is typically a KeySet for tokens this service
+// issues, or a RemoteKeySet for tokens issued by another service.
+//
+// Usage:
+//
+//	keys := bedrock.NewRemoteKeySet("https://auth.internal/.well-known/jwks.json", 0)
+//	auth := bedrock.RequireAuthWithKeys(keys)
+func RequireAuthWithKeys(keys KeyProvider) Middleware {
+	return RequireAuthWithConfig(TokenConfig{Keys: keys})
+}
+
+// RequireAuthWithConfig is like RequireAuth, but validates tokens according
+// to cfg, including its issuer, audience, leeway and required claims.
+// Handlers read the token's claims with GetClaims.
+//
+// Usage:
+//
+//	auth := bedrock.RequireAuthWithConfig(bedrock.TokenConfig{
+//	    Keys:           keys,
+//	    Issuer:         "https://auth.example.com",
+//	    Audience:       []string{"bookings"},
+//	    Leeway:         30 * time.Second,
+//	    RequiredClaims: []string{"exp", "tenant_id"},
+//	})
+func RequireAuthWithConfig(cfg TokenConfig) Middleware {
 	return func(next Handler) Handler {
 		return func(ctx context.Context, r *http.Request) Response {
 			// Extract token from Authorization header
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				return JSON(http.StatusUnauthorized, map[string]string{
-					"error": "missing authorization header",
-				})
+				return Unauthorized("missing authorization header")
 			}
 
 			// Expected format: "Bearer <token>"
 			parts := strings.Split(authHeader, " ")
 			if len(parts) != 2 || parts[0] != "Bearer" {
-				return JSON(http.StatusUnauthorized, map[string]string{
-					"error": "invalid authorization format",
-				})
+				return Unauthorized("invalid authorization format")
 			}
 
 			token := parts[1]
 
 			// Validate token and extract user ID
-			userID, err := ValidateJWT(token, secret)
+			var claims jwt.RegisteredClaims
+			payload, err := parseToken(ctx, token, &claims, cfg)
+			if err != nil || claims.Subject == "" {
+				return Unauthorized("invalid token")
+			}
+
+			// Reject tokens revoked since they were issued
+			revoked, err := isRevoked(ctx, claims)
 			if err != nil {
-				return JSON(http.StatusUnauthorized, map[string]string{
-					"error": "invalid token",
-				})
+				return InternalError().Wrap(err)
+			}
+			if revoked {
+				return Unauthorized("token revoked")
 			}
 
-			// Add user ID to context for downstream handlers
-			ctx = WithUserID(ctx, userID)
+			// Add user ID and claims to context for downstream handlers
+			ctx = WithUserID(ctx, claims.Subject)
+			ctx = withClaims(ctx, payload)
 
 			// Call next handler
 			return next(ctx, r)
@@ -70,7 +106,8 @@ func RequireAuth(secret string) Middleware {
 }
 
 // GenerateJWT creates a signed JWT token for the given user ID.
-// The token includes standard claims (subject, issued at, expiration).
+// The token includes standard claims (token ID, subject, issued at, expiration).
+// The token ID (jti) lets the token be revoked, see Denylist.
 //
 // Parameters:
 //   - userID: The user identifier to embed in the token (stored as "sub" claim)
@@ -83,15 +120,7 @@ func RequireAuth(secret string) Middleware {
 //
 //	token, err := bedrock.GenerateJWT("user123", "secret", 24*time.Hour)
 func GenerateJWT(userID string, secret string, expiration time.Duration) (string, error) {
-	now := time.Now()
-
-	claims := jwt.RegisteredClaims{
-		Subject:   userID,
-		IssuedAt:  jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(now.Add(expiration)),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, NewRegisteredClaims(userID, expiration))
 	return token.SignedString([]byte(secret))
 }
 
@@ -108,36 +137,35 @@ func GenerateJWT(userID string, secret string, expiration time.Duration) (string
 //
 //	userID, err := bedrock.ValidateJWT(token, "secret")
 func ValidateJWT(tokenString string, secret string) (string, error) {
-	// Parse and validate token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method is HMAC
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return []byte(secret), nil
-	})
+	return validateSubject(context.Background(), tokenString, TokenConfig{Secret: secret})
+}
 
-	if err != nil {
-		return "", err
-	}
+// ValidateJWTWithKeys is like ValidateJWT, but verifies an asymmetrically
+// signed token with the key keys returns for its kid and alg headers.
+//
+// Example:
+//
+//	userID, err := bedrock.ValidateJWTWithKeys(ctx, token, keys)
+func ValidateJWTWithKeys(ctx context.Context, tokenString string, keys KeyProvider) (string, error) {
+	return validateSubject(ctx, tokenString, TokenConfig{Keys: keys})
+}
 
-	if !token.Valid {
-		return "", errors.New("invalid token")
-	}
+// asymmetricAlgs are the algorithms KeySet signs with.
+var asymmetricAlgs = []string{"RS256", "ES256", "ES384", "ES512", "EdDSA"}
 
-	// Extract claims
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", errors.New("invalid claims")
+// validateSubject validates a token and returns its "sub" claim.
+func validateSubject(ctx context.Context, tokenString string, cfg TokenConfig) (string, error) {
+	claims, err := ValidateToken[jwt.RegisteredClaims](ctx, tokenString, cfg)
+	if err != nil {
+		return "", err
 	}
 
 	// Extract user ID from "sub" claim
-	userID, ok := claims["sub"].(string)
-	if !ok {
+	if claims.Subject == "" {
 		return "", errors.New("missing user ID in token")
 	}
 
-	return userID, nil
+	return claims.Subject, nil
 }
 
 // WithUserID adds a user ID to the request context.
@@ -147,6 +175,7 @@ func ValidateJWT(tokenString string, secret string) (string, error) {
 //
 //	ctx = bedrock.WithUserID(ctx, "user123")
 func WithUserID(ctx context.Context, userID string) context.Context {
+	recordUserID(ctx, userID)
 	return context.WithValue(ctx, userIDKey, userID)
 }
 
@@ -221,13 +221,13 @@ func TestRequireAuth_MissingToken(t *testing.T) {
 	response := wrappedHandler(context.Background(), req)
 
 	// Should fail with 401
-	jsonResp, ok := response.(JSONResponse)
+	httpErr, ok := response.(*HTTPError)
 	if !ok {
-		t.Fatal("Expected JSONResponse")
+		t.Fatal("Expected *HTTPError")
 	}
 
-	if jsonResp.StatusCode != 401 {
-		t.Errorf("Expected status 401, got %d", jsonResp.StatusCode)
+	if httpErr.Status != 401 {
+		t.Errorf("Expected status 401, got %d", httpErr.Status)
 	}
 }
 
@@ -248,13 +248,13 @@ func TestRequireAuth_InvalidFormat(t *testing.T) {
 	response := wrappedHandler(context.Background(), req)
 
 	// Should fail with 401
-	jsonResp, ok := response.(JSONResponse)
+	httpErr, ok := response.(*HTTPError)
 	if !ok {
-		t.Fatal("Expected JSONResponse")
+		t.Fatal("Expected *HTTPError")
 	}
 
-	if jsonResp.StatusCode != 401 {
-		t.Errorf("Expected status 401, got %d", jsonResp.StatusCode)
+	if httpErr.Status != 401 {
+		t.Errorf("Expected status 401, got %d", httpErr.Status)
 	}
 }
 
@@ -275,13 +275,13 @@ func TestRequireAuth_InvalidToken(t *testing.T) {
 	response := wrappedHandler(context.Background(), req)
 
 	// Should fail with 401
-	jsonResp, ok := response.(JSONResponse)
+	httpErr, ok := response.(*HTTPError)
 	if !ok {
-		t.Fatal("Expected JSONResponse")
+		t.Fatal("Expected *HTTPError")
 	}
 
-	if jsonResp.StatusCode != 401 {
-		t.Errorf("Expected status 401, got %d", jsonResp.StatusCode)
+	if httpErr.Status != 401 {
+		t.Errorf("Expected status 401, got %d", httpErr.Status)
 	}
 }
 
@@ -309,13 +309,13 @@ func TestRequireAuth_WrongSecret(t *testing.T) {
 	response := wrappedHandler(context.Background(), req)
 
 	// Should fail with 401
-	jsonResp, ok := response.(JSONResponse)
+	httpErr, ok := response.(*HTTPError)
 	if !ok {
-		t.Fatal("Expected JSONResponse")
+		t.Fatal("Expected *HTTPError")
 	}
 
-	if jsonResp.StatusCode != 401 {
-		t.Errorf("Expected status 401, got %d", jsonResp.StatusCode)
+	if httpErr.Status != 401 {
+		t.Errorf("Expected status 401, got %d", httpErr.Status)
 	}
 }
 
@@ -0,0 +1,215 @@
+package bedrock
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const authorizerKey contextKey = "authorizer"
+
+// Authorizer decides whether an authenticated user meets a Requirement.
+// Set Options.Authorizer to load roles or permissions from elsewhere, such
+// as a database; the default ClaimsAuthorizer reads them from the token.
+//
+// Example:
+//
+//	type dbAuthorizer struct{ db *sql.DB }
+//
+//	func (a dbAuthorizer) Authorize(ctx context.Context, userID string, req bedrock.Requirement) (bool, error) {
+//	    roles, err := a.loadRoles(ctx, userID)
+//	    if err != nil {
+//	        return false, err
+//	    }
+//	    return slices.ContainsFunc(req.Roles, func(r string) bool { return slices.Contains(roles, r) }), nil
+//	}
+type Authorizer interface {
+	Authorize(ctx context.Context, userID string, req Requirement) (bool, error)
+}
+
+// Requirement is what RequireRole, RequireScope or RequirePermission
+// demands of the user. Exactly one of its kinds is set.
+type Requirement struct {
+	Roles    []string // the user must have at least one of these roles
+	Scopes   []string // the user must have all of these scopes
+	Resource string   // with Action, the user must hold this permission
+	Action   string
+}
+
+// String describes r for logs, e.g. "role admin|editor" or "permission bookings:write".
+func (r Requirement) String() string {
+	switch {
+	case len(r.Roles) > 0:
+		return "role " + strings.Join(r.Roles, "|")
+	case len(r.Scopes) > 0:
+		return "scope " + strings.Join(r.Scopes, " ")
+	default:
+		return "permission " + r.Resource + ":" + r.Action
+	}
+}
+
+// RequireRole creates middleware that allows the request if the user has
+// at least one of roles. It must run after an auth middleware.
+//
+// Unauthenticated requests get a 401, users without the role a 403, and
+// each denial is logged with the route and user ID. It panics if roles is
+// empty.
+//
+// Usage:
+//
+//	routes := []bedrock.Route{
+//	    {
+//	        Method:     "DELETE",
+//	        Path:       "/bookings/{id}",
+//	        Handler:    a.deleteBooking,
+//	        Middleware: []bedrock.Middleware{auth, bedrock.RequireRole("admin", "support")},
+//	    },
+//	}
+func RequireRole(roles ...string) Middleware {
+	if len(roles) == 0 {
+		panic("bedrock: RequireRole needs at least one role")
+	}
+	return requireAuthorization(Requirement{Roles: roles})
+}
+
+// RequireScope creates middleware that allows the request if the user has
+// all of scopes, e.g. OAuth scopes granted to the token. It must run after
+// an auth middleware. It panics if scopes is empty.
+//
+// Usage:
+//
+//	bedrock.RequireScope("bookings:read")
+func RequireScope(scopes ...string) Middleware {
+	if len(scopes) == 0 {
+		panic("bedrock: RequireScope needs at least one scope")
+	}
+	return requireAuthorization(Requirement{Scopes: scopes})
+}
+
+// RequirePermission creates middleware that allows the request if the user
+// may perform action on resource. It must run after an auth middleware.
+// It panics if resource or action is empty.
+//
+// Usage:
+//
+//	bedrock.RequirePermission("bookings", "write")
+func RequirePermission(resource, action string) Middleware {
+	if resource == "" || action == "" {
+		panic("bedrock: RequirePermission needs a resource and an action")
+	}
+	return requireAuthorization(Requirement{Resource: resource, Action: action})
+}
+
+func requireAuthorization(req Requirement) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, r *http.Request) Response {
+			userID, ok := GetUserID(ctx)
+			if !ok {
+				return Unauthorized("authentication required")
+			}
+
+			allowed, err := authorizerFrom(ctx).Authorize(ctx, userID, req)
+			if err != nil {
+				return InternalError().Wrap(err)
+			}
+			if !allowed {
+				Logger(ctx).Warn("authorization denied",
+					"route", routeTemplate(r),
+					"user_id", userID,
+					"requirement", req.String(),
+				)
+				return Forbidden("insufficient permissions")
+			}
+
+			return next(ctx, r)
+		}
+	}
+}
+
+func withAuthorizer(ctx context.Context, authz Authorizer) context.Context {
+	return context.WithValue(ctx, authorizerKey, authz)
+}
+
+func authorizerFrom(ctx context.Context) Authorizer {
+	if authz, ok := ctx.Value(authorizerKey).(Authorizer); ok {
+		return authz
+	}
+	return ClaimsAuthorizer{}
+}
+
+// routeTemplate returns the path template of the matched route, or the
+// request path outside the router.
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
+
+// ClaimsAuthorizer is the default Authorizer. It reads roles, scopes and
+// permissions from the claims of the request's token (see GetClaims).
+//
+//	{"roles": ["admin"], "scope": "bookings:read bookings:write", "permissions": ["bookings:*"]}
+//
+// Scopes may be a space-separated string, as in OAuth 2.0, or an array.
+// Permissions are "resource:action" strings, where either part may be "*";
+// a bare "*" grants every permission.
+type ClaimsAuthorizer struct {
+	RolesClaim       string // optional; defaults to "roles"
+	ScopesClaim      string // optional; defaults to "scope"
+	PermissionsClaim string // optional; defaults to "permissions"
+}
+
+// Authorize implements Authorizer.
+func (a ClaimsAuthorizer) Authorize(ctx context.Context, userID string, req Requirement) (bool, error) {
+	claims, _ := GetClaims[map[string]any](ctx)
+
+	switch {
+	case len(req.Roles) > 0:
+		roles := claimStrings(claims[cmp.Or(a.RolesClaim, "roles")])
+		return slices.ContainsFunc(req.Roles, func(role string) bool {
+			return slices.Contains(roles, role)
+		}), nil
+
+	case len(req.Scopes) > 0:
+		scopes := claimStrings(claims[cmp.Or(a.ScopesClaim, "scope")])
+		for _, scope := range req.Scopes {
+			if !slices.Contains(scopes, scope) {
+				return false, nil
+			}
+		}
+		return true, nil
+
+	default:
+		for _, granted := range claimStrings(claims[cmp.Or(a.PermissionsClaim, "permissions")]) {
+			resource, action, _ := strings.Cut(granted, ":")
+			if granted == "*" || (resource == "*" || resource == req.Resource) && (action == "*" || action == req.Action) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// claimStrings reads a claim holding a string array or a space-separated string.
+func claimStrings(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestClaimsAuthorizer(t *testing.T) {
+	claims := json.RawMessage(`{
+		"sub": "user123",
+		"roles": ["editor"],
+		"scope": "bookings:read bookings:write",
+		"permissions": ["bookings:*", "*:read", "reports:export"]
+	}`)
+	ctx := withClaims(WithUserID(context.Background(), "user123"), claims)
+
+	tests := []struct {
+		name string
+		mw   Middleware
+		want int
+	}{
+		{"matching role", RequireRole("admin", "editor"), 200},
+		{"missing role", RequireRole("admin"), 403},
+		{"all scopes", RequireScope("bookings:read", "bookings:write"), 200},
+		{"one scope missing", RequireScope("bookings:read", "users:read"), 403},
+		{"resource wildcard", RequirePermission("bookings", "delete"), 200},
+		{"action wildcard", RequirePermission("invoices", "read"), 200},
+		{"exact permission", RequirePermission("reports", "export"), 200},
+		{"no permission", RequirePermission("invoices", "write"), 403},
+	}
+	for _, tt := range tests {
+		handler := Chain(func(ctx context.Context, r *http.Request) Response {
+			return JSON(200, nil)
+		}, tt.mw)
+
+		w := httptest.NewRecorder()
+		handler(ctx, httptest.NewRequest("GET", "/", nil)).Write(ctx, w)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, w.Code)
+		}
+	}
+}
+
+func TestClaimsAuthorizer_CustomClaimNames(t *testing.T) {
+	authz := ClaimsAuthorizer{RolesClaim: "groups", ScopesClaim: "scp", PermissionsClaim: "perms"}
+	ctx := withClaims(context.Background(), json.RawMessage(`{"groups": "admin", "scp": ["a", "b"], "perms": ["*"]}`))
+
+	for _, req := range []Requirement{
+		{Roles: []string{"admin"}},
+		{Scopes: []string{"a", "b"}},
+		{Resource: "anything", Action: "delete"},
+	} {
+		if ok, err := authz.Authorize(ctx, "user123", req); !ok || err != nil {
+			t.Errorf("%s: expected allowed, got %v, %v", req, ok, err)
+		}
+	}
+}
+
+// roleAuthorizer is an Authorizer backed by a map, standing in for a database.
+type roleAuthorizer map[string][]string
+
+func (a roleAuthorizer) Authorize(ctx context.Context, userID string, req Requirement) (bool, error) {
+	roles, ok := a[userID]
+	if !ok {
+		return false, errors.New("user not found")
+	}
+	return slices.ContainsFunc(req.Roles, func(role string) bool { return slices.Contains(roles, role) }), nil
+}
+
+func TestRequireRole_WithAuthorizer(t *testing.T) {
+	secret := "test-secret"
+	group := Group("/admin", RequireAuth(secret), RequireRole("admin"))
+	group.Add(Route{Method: "GET", Path: "/bookings/{id}", Handler: func(ctx context.Context, r *http.Request) Response {
+		return JSON(200, nil)
+	}})
+
+	var buf bytes.Buffer
+	router := testRouter(group.Routes(), Options{Authorizer: roleAuthorizer{
+		"alice": {"admin"},
+		"bob":   {"viewer"},
+	}}, &buf)
+
+	request := func(userID string) *httptest.ResponseRecorder {
+		token, _ := GenerateJWT(userID, secret, time.Hour)
+		req := httptest.NewRequest("GET", "/admin/bookings/42", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := request("alice"); w.Code != 200 {
+		t.Errorf("alice: expected 200, got %d", w.Code)
+	}
+
+	w := request("bob")
+	if w.Code != 403 {
+		t.Fatalf("bob: expected 403, got %d", w.Code)
+	}
+	body := decodeBody(t, w)
+	if body["code"] != "forbidden" || body["error"] != "insufficient permissions" || body["request_id"] == nil {
+		t.Errorf("unexpected 403 body: %v", body)
+	}
+
+	var denial map[string]any
+	for _, line := range logLines(t, &buf) {
+		if line["msg"] == "authorization denied" {
+			denial = line
+		}
+	}
+	if denial == nil {
+		t.Fatalf("expected denial to be logged:\n%s", buf.String())
+	}
+	if denial["route"] != "/admin/bookings/{id}" || denial["user_id"] != "bob" || denial["requirement"] != "role admin" {
+		t.Errorf("unexpected denial log: %v", denial)
+	}
+
+	// Authorizer errors are server errors, not denials, and their cause is logged
+	buf.Reset()
+	if w := request("mallory"); w.Code != 500 {
+		t.Errorf("mallory: expected 500, got %d", w.Code)
+	}
+	if !slices.ContainsFunc(logLines(t, &buf), func(line map[string]any) bool {
+		return line["msg"] == "request failed" && line["err"] == "user not found"
+	}) {
+		t.Errorf("expected the authorizer error to be logged:\n%s", buf.String())
+	}
+}
+
+func TestRequireRole_Unauthenticated(t *testing.T) {
+	handler := Chain(func(ctx context.Context, r *http.Request) Response {
+		return JSON(200, nil)
+	}, RequireRole("admin"))
+
+	w := httptest.NewRecorder()
+	handler(context.Background(), httptest.NewRequest("GET", "/", nil)).Write(context.Background(), w)
+	if w.Code != 401 {
+		t.Errorf("expected 401 without an auth middleware, got %d", w.Code)
+	}
+}
+
+func TestRequire_EmptyRequirementPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		make func() Middleware
+	}{
+		{"no roles", func() Middleware { return RequireRole() }},
+		{"no scopes", func() Middleware { return RequireScope() }},
+		{"no resource", func() Middleware { return RequirePermission("", "read") }},
+		{"no action", func() Middleware { return RequirePermission("bookings", "") }},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", tt.name)
+				}
+			}()
+			tt.make()
+		}()
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"regexp"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -37,7 +39,16 @@ type Route struct {
 	Path       string
 	Handler    Handler
 	Middleware []Middleware // Optional per-route middleware
-	IsPrefix   bool        // If true, matches all paths with this prefix
+	IsPrefix   bool         // If true, matches all paths with this prefix
+
+	// Optional OpenAPI metadata, see GenerateOpenAPI
+	Summary  string   // Short description of the operation
+	Tags     []string // Groups operations in generated docs and clients
+	Request  any      // Zero value of the request type, e.g. CreateBooking{}
+	Response any      // Zero value of the 200 response body type, e.g. Booking{} or []Booking{}
+	Security []string // Names of the OpenAPIConfig.SecuritySchemes the route requires, e.g. "bearer"
+
+	scope *routeScope // set by RouteGroup.Routes
 }
 
 // CORSConfig holds CORS configuration
@@ -52,17 +63,65 @@ type CORSConfig struct {
 
 // Options configures optional bedrock behaviour.
 type Options struct {
-	CORS   *CORSConfig
-	Logger *slog.Logger // optional; defaults to slog.Default()
+	CORS             *CORSConfig
+	Logger           *slog.Logger   // optional; defaults to slog.Default()
+	ShutdownTimeout  time.Duration  // optional; defaults to 30s
+	Metrics          *Registry      // optional; defaults to DefaultRegistry, served on BaseConfig.MetricsPort
+	DisableAccessLog bool           // optional; disables the per-request access log line
+	RequestIDHeader  string         // optional; defaults to DefaultRequestIDHeader ("X-Request-ID")
+	OnPanic          PanicHandler   // optional; called after a handler panic is recovered and logged
+	Errors           *ErrorMapper   // optional; defaults to DefaultErrorMapper, used by FromError
+	ProblemDetails   bool           // optional; render HTTPErrors as RFC 7807 application/problem+json
+	OpenAPI          *OpenAPIConfig // optional; if set, the generated OpenAPI document is served on the main server
+	Authorizer       Authorizer     // optional; defaults to ClaimsAuthorizer, used by RequireRole, RequireScope and RequirePermission
+	Revoker          Revoker        // optional; if set, auth middleware rejects the tokens it reports as revoked
+
+	// Middleware wraps every application route, before its own Route.Middleware.
+	Middleware []Middleware
+	// HTTPMiddleware wraps every application route with net/http middleware,
+	// before Middleware. It runs after CORS, request IDs, access logging and
+	// panic recovery, and is not applied to preflight requests or to the
+	// health, metrics and OpenAPI endpoints.
+	HTTPMiddleware []func(http.Handler) http.Handler
+}
+
+// withDefaults returns a copy of o with every optional field filled in.
+func (o Options) withDefaults() Options {
+	if o.Logger == nil {
+		o.Logger = slog.Default()
+	}
+	if o.CORS == nil {
+		corsConfig := DefaultCORSConfig()
+		o.CORS = &corsConfig
+	}
+	if o.ShutdownTimeout == 0 {
+		o.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if o.Metrics == nil {
+		o.Metrics = DefaultRegistry
+	}
+	if o.RequestIDHeader == "" {
+		o.RequestIDHeader = DefaultRequestIDHeader
+	}
+	if o.Errors == nil {
+		o.Errors = DefaultErrorMapper
+	}
+	if o.Authorizer == nil {
+		o.Authorizer = ClaimsAuthorizer{}
+	}
+	return o
 }
 
+// defaultShutdownTimeout bounds graceful server shutdown when Options.ShutdownTimeout is unset.
+const defaultShutdownTimeout = 30 * time.Second
+
 // DefaultCORSConfig returns a permissive CORS config for development
 func DefaultCORSConfig() CORSConfig {
 	return CORSConfig{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", DefaultRequestIDHeader},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}
@@ -76,18 +135,44 @@ func RunWithCORS(app App, cfg config.BaseConfig, corsConfig CORSConfig) error {
 	return RunWithOptions(app, cfg, Options{CORS: &corsConfig})
 }
 
+// RunWithOptions runs the app until SIGINT or SIGTERM is received.
+// See RunContext for the full lifecycle.
 func RunWithOptions(app App, cfg config.BaseConfig, opts Options) error {
-	logger := opts.Logger
-	if logger == nil {
-		logger = slog.Default()
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	corsConfig := DefaultCORSConfig()
-	if opts.CORS != nil {
-		corsConfig = *opts.CORS
-	}
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
 
-	ctx := context.Background()
+	go func() {
+		select {
+		case <-quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return RunContext(ctx, app, cfg, opts)
+}
+
+// RunContext runs the app until ctx is cancelled or one of its servers fails.
+//
+// It returns nil after a clean shutdown. If a server fails to listen, the
+// first such error is returned after shutdown. If ctx was cancelled with a
+// cause (see context.WithCancelCause), that cause is returned, which lets a
+// supervisor or a goroutine started in OnStart stop the app with a fatal error.
+//
+// All three modes (merged health endpoints, separate health server, and
+// background with no routes) share the same shutdown sequence: mark not
+// ready, shut down servers, stop jobs, then call app.OnStop.
+//
+// If BaseConfig.MetricsPort (or NOMAD_PORT_metrics) is set, Prometheus metrics
+// are served at /metrics on that port. When it equals the HTTP or health port,
+// /metrics is added to that server instead of starting a new one.
+func RunContext(ctx context.Context, app App, cfg config.BaseConfig, opts Options) error {
+	opts = opts.withDefaults()
+	logger := opts.Logger
 
 	// Create health status tracker
 	healthStatus := newHealthStatus()
@@ -102,28 +187,90 @@ func RunWithOptions(app App, cfg config.BaseConfig, opts Options) error {
 		}
 	}
 
+	// Servers are started in order and shut down in the same order.
+	// Listener errors are collected on serveErrs; the first one ends the run.
+	var servers []*http.Server
+	serveErrs := make(chan error, 3)
+	serve := func(name string, server *http.Server) {
+		servers = append(servers, server)
+		go func() {
+			logger.Info("starting "+name, "addr", server.Addr)
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				serveErrs <- fmt.Errorf("%s: %w", name, err)
+			}
+		}()
+	}
+
+	// stop runs the unified shutdown sequence. started reports whether
+	// OnStart succeeded, in which case jobs and OnStop are run as well.
+	stop := func(started bool) {
+		// Mark as not ready (stop accepting new traffic)
+		healthStatus.SetReady(false)
+
+		// Graceful shutdown
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), opts.ShutdownTimeout)
+		defer cancel()
+
+		for _, server := range servers {
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				logger.Error("server forced to shutdown", "addr", server.Addr, "err", err)
+			}
+		}
+
+		if !started {
+			return
+		}
+
+		// Stop scheduled jobs
+		if jobs != nil {
+			jobs.Stop()
+		}
+
+		// Call app.OnStop()
+		if err := app.OnStop(context.WithoutCancel(ctx)); err != nil {
+			logger.Error("error during OnStop", "err", err)
+		}
+	}
+
 	// Determine if we should merge health endpoints into main server
 	// This happens when HTTP and Health ports are the same
 	mergeServers := cfg.HTTPPort == cfg.HealthPort
 
+	// Metrics are disabled when no metrics port is configured
+	metricsPort := cfg.GetMetricsPort()
+	var metricsOnHealth, metricsOnMain bool
+	if metricsPort != 0 {
+		metricsOnHealth = !mergeServers && metricsPort == cfg.HealthPort
+		metricsOnMain = metricsPort == cfg.HTTPPort
+	}
+
 	// Only start separate health server if ports differ
-	var healthServer *http.Server
 	if !mergeServers {
+		var healthMetrics *Registry
+		if metricsOnHealth {
+			healthMetrics = opts.Metrics
+		}
 		// Start health server BEFORE calling OnStart
 		// This way Nomad/K8s can see the container is alive
-		healthServer = startHealthServer(strconv.Itoa(cfg.HealthPort), healthStatus)
+		serve("health server", newHealthServer(strconv.Itoa(cfg.HealthPort), healthStatus, healthMetrics))
 	} else {
 		logger.Info("health endpoints will be merged into main server", "port", cfg.HTTPPort)
 	}
 
 	// Call app.OnStart()
 	if err := app.OnStart(ctx); err != nil {
+		stop(false)
 		return fmt.Errorf("failed to start app: %w", err)
 	}
 
 	// OnStart succeeded, mark as healthy
 	healthStatus.SetHealthy(true)
 
+	// Register dependency checks now that OnStart has initialised them
+	if hc, ok := app.(HealthChecker); ok {
+		healthStatus.SetChecks(hc.HealthChecks())
+	}
+
 	// Start cron runner after app is healthy
 	if jobs != nil {
 		jobs.Start()
@@ -132,18 +279,65 @@ func RunWithOptions(app App, cfg config.BaseConfig, opts Options) error {
 
 	routes := app.Routes()
 
+	// Without a main server, metrics on the HTTP port need their own server
+	if metricsOnMain && len(routes) == 0 && !mergeServers {
+		metricsOnMain = false
+	}
+	if metricsPort != 0 && !metricsOnMain && !metricsOnHealth {
+		serve("metrics server", newMetricsServer(strconv.Itoa(metricsPort), opts.Metrics))
+	}
+
 	// Validate routes don't conflict with reserved health endpoints when merging
 	if mergeServers {
 		reservedPaths := []string{"/health", "/ready", "/live"}
 		for _, route := range routes {
 			for _, reserved := range reservedPaths {
 				if route.Path == reserved {
+					stop(true)
 					return fmt.Errorf("route conflict: application route %s conflicts with reserved health endpoint %s", route.Path, reserved)
 				}
 			}
 		}
 	}
 
+	// Likewise for /metrics when it is served on the main router
+	if metricsOnMain {
+		for _, route := range routes {
+			if route.Path == "/metrics" {
+				stop(true)
+				return fmt.Errorf("route conflict: application route %s conflicts with reserved metrics endpoint /metrics", route.Path)
+			}
+		}
+	}
+
+	rc := routerConfig{opts: opts}
+
+	// The OpenAPI document is generated once, from the routes being served
+	if opts.OpenAPI != nil && len(routes) > 0 {
+		openAPI := opts.OpenAPI.withDefaults()
+		for _, route := range routes {
+			if route.Path == openAPI.Path {
+				stop(true)
+				return fmt.Errorf("route conflict: application route %s conflicts with OpenAPI endpoint %s", route.Path, openAPI.Path)
+			}
+		}
+		doc, err := GenerateOpenAPI(routes, openAPI)
+		if err != nil {
+			stop(true)
+			return err
+		}
+		rc.openAPIPath, rc.openAPI = openAPI.Path, doc
+	}
+	if mergeServers {
+		rc.health = healthStatus
+	}
+	if metricsOnMain {
+		rc.metrics = opts.Metrics
+	}
+	if metricsPort != 0 {
+		rc.instr = newHTTPMetrics(opts.Metrics)
+	}
+
 	if len(routes) == 0 {
 		// No HTTP routes, running in background mode
 		if mergeServers {
@@ -154,190 +348,203 @@ func RunWithOptions(app App, cfg config.BaseConfig, opts Options) error {
 			router := mux.NewRouter()
 
 			// Register health endpoints (no CORS needed for health checks)
-			router.HandleFunc("/health", healthCheckHandler(healthStatus))
-			router.HandleFunc("/ready", readyCheckHandler(healthStatus))
-			router.HandleFunc("/live", liveCheckHandler(healthStatus))
+			registerHealthEndpoints(router, healthStatus)
+			if metricsOnMain {
+				registerMetricsEndpoint(router, opts.Metrics)
+			}
 
-			server := &http.Server{
+			serve("health-only server", &http.Server{
 				Addr:    ":" + strconv.Itoa(cfg.HTTPPort),
 				Handler: router,
-			}
-
-			go func() {
-				logger.Info("starting health-only server", "port", cfg.HTTPPort)
-				if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-					logger.Error("server error", "err", err)
-				}
-			}()
-
-			// Mark as ready
-			healthStatus.SetReady(true)
-
-			// Wait for shutdown signal
-			quit := make(chan os.Signal, 1)
-			signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-			<-quit
-
-			logger.Info("shutting down")
-
-			// Shutdown server
-			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-			server.Shutdown(shutdownCtx)
-
-			// Stop scheduled jobs
-			if jobs != nil {
-				jobs.Stop()
-			}
+			})
+		} else {
+			// Separate health server is already running
+			logger.Info("no HTTP routes, running in background mode")
+		}
+	} else {
+		serve("server", &http.Server{
+			Addr:    ":" + strconv.Itoa(cfg.HTTPPort),
+			Handler: newRouter(routes, rc),
+		})
+	}
 
-			// Call app.OnStop()
-			if err := app.OnStop(ctx); err != nil {
-				logger.Error("error during OnStop", "err", err)
-			}
+	// Servers are up, mark as ready
+	healthStatus.SetReady(true)
 
-			return nil
+	// Wait for cancellation or a fatal server error
+	var runErr error
+	select {
+	case <-ctx.Done():
+		if cause := context.Cause(ctx); cause != ctx.Err() {
+			runErr = cause
 		}
+	case runErr = <-serveErrs:
+		logger.Error("server error", "err", runErr)
+	}
 
-		// Separate health server is already running
-		logger.Info("no HTTP routes, running in background mode")
-
-		// Mark as ready (no HTTP server to wait for)
-		healthStatus.SetReady(true)
+	logger.Info("shutting down")
+	stop(true)
+	logger.Info("stopped")
 
-		// Wait for shutdown signal
-		quit := make(chan os.Signal, 1)
-		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-		<-quit
+	return runErr
+}
 
-		logger.Info("shutting down")
+// routerConfig describes what the main router serves besides the app routes.
+type routerConfig struct {
+	opts    Options       // with defaults applied
+	health  *HealthStatus // if set, health endpoints are merged into the router
+	metrics *Registry     // if set, /metrics is merged into the router
+	instr   *httpMetrics  // if set, app routes record built-in HTTP metrics
 
-		// Shutdown health server
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		healthServer.Shutdown(shutdownCtx)
+	openAPIPath string // where openAPI is served
+	openAPI     []byte // if set, the generated OpenAPI document
+}
 
-		// Stop scheduled jobs
-		if jobs != nil {
-			jobs.Stop()
-		}
+// newRouter builds the main router from the app routes, wrapped with CORS.
+func newRouter(routes []Route, rc routerConfig) http.Handler {
+	router := mux.NewRouter()
 
-		// Call app.OnStop()
-		if err := app.OnStop(ctx); err != nil {
-			logger.Error("error during OnStop", "err", err)
+	// Unmatched requests get the same error format as handler errors
+	errCfg := errorConfig{mapper: rc.opts.Errors, problemDetails: rc.opts.ProblemDetails}
+	notFound := routerErrorHandler(errCfg, NotFound("not found"))
+	methodNotAllowed := routerErrorHandler(errCfg, NewHTTPError(http.StatusMethodNotAllowed, "", "method not allowed"))
+	router.MethodNotAllowedHandler = methodNotAllowed
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// gorilla/mux loses method mismatches inside subrouters (see
+		// RouteGroup). Every app path also has an OPTIONS route, so if that
+		// matches, the path exists and only the method is wrong.
+		if req.Method != http.MethodOptions {
+			preflight := req.Clone(req.Context())
+			preflight.Method = http.MethodOptions
+			var match mux.RouteMatch
+			if router.Match(preflight, &match) && match.MatchErr == nil {
+				methodNotAllowed.ServeHTTP(w, req)
+				return
+			}
 		}
-
-		return nil
-	}
-
-	// Create main HTTP server
-	router := mux.NewRouter()
+		notFound.ServeHTTP(w, req)
+	})
 
 	// If merging servers, add health endpoints to main router BEFORE app routes
 	// Health endpoints should NOT have CORS or app middleware applied
-	if mergeServers {
-		router.HandleFunc("/health", healthCheckHandler(healthStatus))
-		router.HandleFunc("/ready", readyCheckHandler(healthStatus))
-		router.HandleFunc("/live", liveCheckHandler(healthStatus))
-		logger.Info("health endpoints registered on main router")
+	if rc.health != nil {
+		registerHealthEndpoints(router, rc.health)
+		rc.opts.Logger.Info("health endpoints registered on main router")
+	}
+	if rc.metrics != nil {
+		registerMetricsEndpoint(router, rc.metrics)
+		rc.opts.Logger.Info("metrics endpoint registered on main router")
+	}
+	if rc.openAPI != nil {
+		registerOpenAPIEndpoint(router, rc.openAPIPath, rc.openAPI)
 	}
 
-	// Register app routes
+	// Register app routes. Consecutive routes from the same group share a
+	// subrouter, so registration (and therefore matching) order is unchanged.
+	var sub *mux.Router
+	var subScope *routeScope
 	for _, route := range routes {
 		r := route
 
-		// Apply middleware if present
-		handler := r.Handler
-		if len(r.Middleware) > 0 {
-			handler = Chain(handler, r.Middleware...)
-		}
+		// Apply global then route middleware
+		middleware := make([]Middleware, 0, len(rc.opts.Middleware)+len(r.Middleware))
+		middleware = append(middleware, rc.opts.Middleware...)
+		middleware = append(middleware, r.Middleware...)
+		handler := Chain(r.Handler, middleware...)
 
-		// Register the route
-		handlerFunc := func(w http.ResponseWriter, req *http.Request) {
+		var app http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 			ctx := req.Context()
 			response := handler(ctx, req)
 			if err := response.Write(ctx, w); err != nil {
 				http.Error(w, "Internal Server Error", 500)
 			}
+		})
+		for i := len(rc.opts.HTTPMiddleware) - 1; i >= 0; i-- {
+			app = rc.opts.HTTPMiddleware[i](app)
+		}
+
+		// Register the route
+		var prefix *regexp.Regexp // matches the prefix of IsPrefix routes, set once registered
+		cors := rc.opts.CORS
+		if r.scope != nil && r.scope.cors != nil {
+			cors = r.scope.cors
+		}
+		var handlerFunc http.HandlerFunc = func(w http.ResponseWriter, req *http.Request) {
+			ctx := withErrorConfig(req.Context(), errCfg)
+			ctx = withAuthorizer(ctx, rc.opts.Authorizer)
+			ctx = withCORSConfig(ctx, cors)
+			if rc.opts.Revoker != nil {
+				ctx = withRevoker(ctx, rc.opts.Revoker)
+			}
+			if prefix != nil {
+				ctx = withPathRemainder(ctx, req.URL.Path, prefix.FindString(req.URL.Path))
+			}
+			req = req.WithContext(ctx)
+			defer recoverPanic(ctx, w, req, rc.opts.OnPanic)
+
+			app.ServeHTTP(w, req)
 		}
-		optionsFunc := func(w http.ResponseWriter, req *http.Request) {
+		handlerFunc = requestLogging(rc.opts, r.Method, r.Path, handlerFunc)
+		if rc.instr != nil {
+			handlerFunc = rc.instr.instrument(r.Method, r.Path, handlerFunc)
+		}
+		var optionsFunc http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 			// Preflight requests just return 200 OK with CORS headers
 			w.WriteHeader(http.StatusOK)
+		})
+		var routeHandler http.Handler = handlerFunc
+
+		// Group CORS overrides replace the headers set by the app-wide config
+		if r.scope != nil && r.scope.cors != nil {
+			groupCORS := corsMiddleware(*r.scope.cors)
+			routeHandler, optionsFunc = groupCORS(routeHandler), groupCORS(optionsFunc)
+		}
+
+		target, path := router, r.Path
+		if r.scope != nil && r.scope.prefix != "" && strings.HasPrefix(r.Path, r.scope.prefix) {
+			if subScope == nil || *subScope != *r.scope {
+				sub, subScope = router.PathPrefix(r.scope.prefix).Subrouter(), r.scope
+			}
+			target, path = sub, strings.TrimPrefix(r.Path, r.scope.prefix)
 		}
 
 		if r.IsPrefix {
-			router.PathPrefix(r.Path).HandlerFunc(handlerFunc).Methods(r.Method)
-			router.PathPrefix(r.Path).HandlerFunc(optionsFunc).Methods("OPTIONS")
+			muxRoute := target.PathPrefix(path).Handler(routeHandler).Methods(r.Method)
+			target.PathPrefix(path).Handler(optionsFunc).Methods("OPTIONS")
+			if pattern, err := muxRoute.GetPathRegexp(); err == nil {
+				prefix = regexp.MustCompile(pattern)
+			}
 		} else {
-			router.HandleFunc(r.Path, handlerFunc).Methods(r.Method)
-			router.HandleFunc(r.Path, optionsFunc).Methods("OPTIONS")
+			target.Handle(path, routeHandler).Methods(r.Method)
+			target.Handle(path, optionsFunc).Methods("OPTIONS")
 		}
 	}
 
 	// Wrap router with CORS middleware
 	// Note: Health endpoints are registered before CORS, so they won't have CORS applied
 	// This is correct - health checks are infrastructure endpoints
-	corsHandler := corsMiddleware(corsConfig)(router)
-
-	server := &http.Server{
-		Addr:    ":" + strconv.Itoa(cfg.HTTPPort),
-		Handler: corsHandler,
-	}
-
-	// Start main server
-	go func() {
-		logger.Info("starting server", "port", cfg.HTTPPort)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Error("server error", "err", err)
-		}
-	}()
-
-	// Server is up, mark as ready
-	healthStatus.SetReady(true)
-
-	// Wait for shutdown signal
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	logger.Info("shutting down servers")
-
-	// Mark as not ready (stop accepting new traffic)
-	healthStatus.SetReady(false)
-
-	// Graceful shutdown
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	// Shutdown main server
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		logger.Error("main server forced to shutdown", "err", err)
-	}
-
-	// Shutdown health server only if it's separate
-	if !mergeServers {
-		if err := healthServer.Shutdown(shutdownCtx); err != nil {
-			logger.Error("health server forced to shutdown", "err", err)
-		}
-	}
-
-	// Stop scheduled jobs
-	if jobs != nil {
-		jobs.Stop()
-	}
-
-	// Call app.OnStop()
-	if err := app.OnStop(ctx); err != nil {
-		logger.Error("error during OnStop", "err", err)
-	}
+	return corsMiddleware(*rc.opts.CORS)(router)
+}
 
-	logger.Info("servers stopped")
-	return nil
+// corsHeaders are the response headers set by corsMiddleware.
+var corsHeaders = []string{
+	"Access-Control-Allow-Origin",
+	"Access-Control-Allow-Methods",
+	"Access-Control-Allow-Headers",
+	"Access-Control-Expose-Headers",
+	"Access-Control-Allow-Credentials",
+	"Access-Control-Max-Age",
 }
 
 // corsMiddleware wraps an http.Handler with CORS headers
 func corsMiddleware(cfg CORSConfig) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Clear headers set by an outer config, so a group override replaces it
+			for _, header := range corsHeaders {
+				w.Header().Del(header)
+			}
+
 			origin := r.Header.Get("Origin")
 
 			// Check if origin is allowed
@@ -415,6 +622,9 @@ type JSONResponse struct {
 	StatusCode int
 	Data       any
 	Headers    http.Header
+
+	// withRequestID adds the request ID to map bodies, for error responses.
+	withRequestID bool
 }
 
 func (r JSONResponse) Write(ctx context.Context, w http.ResponseWriter) error {
@@ -425,7 +635,38 @@ func (r JSONResponse) Write(ctx context.Context, w http.ResponseWriter) error {
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(r.StatusCode)
-	return json.NewEncoder(w).Encode(r.Data)
+
+	data := r.Data
+	if r.withRequestID {
+		data = addRequestID(ctx, data)
+	}
+	return json.NewEncoder(w).Encode(data)
+}
+
+// addRequestID returns a copy of a map body with a "request_id" field
+// set from the context. Other bodies are returned unchanged.
+func addRequestID(ctx context.Context, data any) any {
+	requestID, ok := GetRequestID(ctx)
+	if !ok {
+		return data
+	}
+	switch d := data.(type) {
+	case map[string]string:
+		out := make(map[string]string, len(d)+1)
+		for k, v := range d {
+			out[k] = v
+		}
+		out["request_id"] = requestID
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(d)+1)
+		for k, v := range d {
+			out[k] = v
+		}
+		out["request_id"] = requestID
+		return out
+	}
+	return data
 }
 
 func JSON(statusCode int, data any) Response {
@@ -436,6 +677,8 @@ func JSONWithHeaders(statusCode int, data any, headers http.Header) Response {
 	return JSONResponse{StatusCode: statusCode, Data: data, Headers: headers}
 }
 
+// Error returns a 500 JSON response. If data is a map, the request ID is
+// added to it as "request_id" so the error can be traced in the logs.
 func Error(data any) Response {
-	return JSONResponse{StatusCode: 500, Data: data}
+	return JSONResponse{StatusCode: 500, Data: data, withRequestID: true}
 }
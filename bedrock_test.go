@@ -0,0 +1,239 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Jack4Code/bedrock/config"
+)
+
+// testApp is a minimal App used to exercise the server lifecycle.
+type testApp struct {
+	routes  []Route
+	onStart func(ctx context.Context) error
+	stopped atomic.Bool
+}
+
+func (a *testApp) OnStart(ctx context.Context) error {
+	if a.onStart != nil {
+		return a.onStart(ctx)
+	}
+	return nil
+}
+
+func (a *testApp) OnStop(ctx context.Context) error {
+	a.stopped.Store(true)
+	return nil
+}
+
+func (a *testApp) Routes() []Route {
+	return a.routes
+}
+
+// freePort returns a TCP port that is free at the time of the call.
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find free port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// quietOptions returns Options with a logger that discards output.
+func quietOptions() Options {
+	return Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+// runInBackground starts RunContext and returns a channel with its result.
+func runInBackground(ctx context.Context, app App, cfg config.BaseConfig, opts Options) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- RunContext(ctx, app, cfg, opts)
+	}()
+	return done
+}
+
+// testClient disables keep-alives so lingering client connections
+// never delay graceful server shutdown.
+var testClient = &http.Client{
+	Transport: &http.Transport{DisableKeepAlives: true},
+	Timeout:   time.Second,
+}
+
+// waitForStatus polls url until it returns the wanted status code.
+func waitForStatus(t *testing.T, url string, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := testClient.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == want {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("%s did not return %d in time", url, want)
+}
+
+// waitForResult waits for RunContext to return.
+func waitForResult(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext did not return in time")
+		return nil
+	}
+}
+
+func helloRoute() Route {
+	return Route{
+		Method: "GET",
+		Path:   "/hello",
+		Handler: func(ctx context.Context, r *http.Request) Response {
+			return JSON(200, map[string]string{"message": "hello"})
+		},
+	}
+}
+
+func TestRunContext_MergedShutdownOnCancel(t *testing.T) {
+	port := freePort(t)
+	app := &testApp{routes: []Route{helloRoute()}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := runInBackground(ctx, app, config.BaseConfig{HTTPPort: port, HealthPort: port}, quietOptions())
+
+	base := fmt.Sprintf("http://127.0.0.1:%d", port)
+	waitForStatus(t, base+"/ready", 200)
+	waitForStatus(t, base+"/hello", 200)
+
+	cancel()
+	if err := waitForResult(t, done); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !app.stopped.Load() {
+		t.Error("OnStop was not called")
+	}
+}
+
+func TestRunContext_SeparateHealthServer(t *testing.T) {
+	httpPort, healthPort := freePort(t), freePort(t)
+	app := &testApp{routes: []Route{helloRoute()}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := runInBackground(ctx, app, config.BaseConfig{HTTPPort: httpPort, HealthPort: healthPort}, quietOptions())
+
+	waitForStatus(t, fmt.Sprintf("http://127.0.0.1:%d/ready", healthPort), 200)
+	waitForStatus(t, fmt.Sprintf("http://127.0.0.1:%d/hello", httpPort), 200)
+
+	cancel()
+	if err := waitForResult(t, done); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	// Both servers must be closed after shutdown
+	for _, port := range []int{httpPort, healthPort} {
+		if _, err := testClient.Get(fmt.Sprintf("http://127.0.0.1:%d/health", port)); err == nil {
+			t.Errorf("port %d still accepting requests after shutdown", port)
+		}
+	}
+}
+
+func TestRunContext_BackgroundMode(t *testing.T) {
+	healthPort := freePort(t)
+	app := &testApp{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := runInBackground(ctx, app, config.BaseConfig{HTTPPort: freePort(t), HealthPort: healthPort}, quietOptions())
+
+	waitForStatus(t, fmt.Sprintf("http://127.0.0.1:%d/ready", healthPort), 200)
+
+	cancel()
+	if err := waitForResult(t, done); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !app.stopped.Load() {
+		t.Error("OnStop was not called")
+	}
+}
+
+func TestRunContext_ReturnsListenerError(t *testing.T) {
+	// Occupy the port so ListenAndServe fails
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	app := &testApp{routes: []Route{helloRoute()}}
+	done := runInBackground(context.Background(), app, config.BaseConfig{HTTPPort: port, HealthPort: port}, quietOptions())
+
+	err = waitForResult(t, done)
+	if err == nil || !strings.Contains(err.Error(), "address already in use") {
+		t.Fatalf("expected address in use error, got %v", err)
+	}
+	if !app.stopped.Load() {
+		t.Error("OnStop was not called")
+	}
+}
+
+func TestRunContext_ReturnsCancelCause(t *testing.T) {
+	port := freePort(t)
+	fatal := errors.New("worker failed")
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	app := &testApp{
+		routes: []Route{helloRoute()},
+		onStart: func(ctx context.Context) error {
+			// Simulate a background worker that fails after startup
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				cancel(fatal)
+			}()
+			return nil
+		},
+	}
+
+	done := runInBackground(ctx, app, config.BaseConfig{HTTPPort: port, HealthPort: port}, quietOptions())
+
+	if err := waitForResult(t, done); !errors.Is(err, fatal) {
+		t.Fatalf("expected %v, got %v", fatal, err)
+	}
+}
+
+func TestRunContext_OnStartErrorStopsHealthServer(t *testing.T) {
+	healthPort := freePort(t)
+	startErr := errors.New("boom")
+	app := &testApp{
+		routes: []Route{helloRoute()},
+		onStart: func(ctx context.Context) error {
+			return startErr
+		},
+	}
+
+	err := RunContext(context.Background(), app, config.BaseConfig{HTTPPort: freePort(t), HealthPort: healthPort}, quietOptions())
+	if !errors.Is(err, startErr) {
+		t.Fatalf("expected %v, got %v", startErr, err)
+	}
+	if app.stopped.Load() {
+		t.Error("OnStop should not be called when OnStart fails")
+	}
+	if _, err := testClient.Get(fmt.Sprintf("http://127.0.0.1:%d/health", healthPort)); err == nil {
+		t.Error("health server still running after OnStart failure")
+	}
+}
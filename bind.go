@@ -0,0 +1,267 @@
+package bedrock
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`          // e.g. "query.limit", "path.id", "body.email"
+	Rule    string `json:"rule,omitempty"` // the failing validation rule, e.g. "required"
+	Message string `json:"message"`        // e.g. "must be an integer"
+}
+
+// Bind populates v, which must be a pointer, from the request.
+//
+// A non-empty body is decoded as JSON into v, as strictly as by
+// DecodeAndValidate: bodies over DefaultMaxBodyBytes get a 413, and unknown
+// fields and trailing data a 400. Then, if v points to a struct, fields
+// tagged `path:"name"`, `query:"name"` or `header:"Name"` are set from the
+// path variables, query string and headers. The body never sets them, even
+// when their source is absent. Supported field types are
+// strings, bools, integers, floats, types implementing encoding.TextUnmarshaler
+// (such as time.Time, in RFC 3339), pointers to those, and slices of those
+// (repeated query parameters or headers). Embedded structs are bound too.
+//
+// On failure Bind returns a 400 *HTTPError listing every rejected field in
+// Details as []FieldError.
+//
+// Example:
+//
+//	type ListBookings struct {
+//	    RoomID string `path:"roomID"`
+//	    Limit  int    `query:"limit"`
+//	    Tenant string `header:"X-Tenant"`
+//	}
+//
+//	var req ListBookings
+//	if err := bedrock.Bind(r, &req); err != nil {
+//	    return bedrock.FromError(err)
+//	}
+func Bind(r *http.Request, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("bedrock: Bind requires a non-nil pointer, got %T", v)
+	}
+
+	var fieldErrs []FieldError
+
+	elem := rv.Elem()
+	if r.Body != nil && r.Body != http.NoBody {
+		// Keep tagged fields out of the body's reach
+		var tagged, saved []reflect.Value
+		if elem.Kind() == reflect.Struct {
+			tagged = taggedFields(elem)
+			for _, field := range tagged {
+				saved = append(saved, reflect.New(field.Type()).Elem())
+				saved[len(saved)-1].Set(field)
+				field.SetZero()
+			}
+		}
+		err := decodeStrict(r, v, DefaultMaxBodyBytes)
+		for i, field := range tagged {
+			field.Set(saved[i])
+		}
+
+		var httpErr *HTTPError
+		switch {
+		case errors.As(err, &httpErr) && httpErr.Status == http.StatusBadRequest:
+			fieldErrs = append(fieldErrs, httpErr.Details.([]FieldError)...)
+		case err != nil && !errors.Is(err, io.EOF):
+			return err
+		}
+	}
+
+	if elem.Kind() == reflect.Struct {
+		fieldErrs = append(fieldErrs, bindTagged(elem, r, mux.Vars(r))...)
+	}
+
+	if len(fieldErrs) > 0 {
+		return BadRequest("invalid request").WithDetails(fieldErrs)
+	}
+	return nil
+}
+
+// jsonFieldError describes a JSON decoding error as a FieldError.
+func jsonFieldError(err error) FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return FieldError{
+			Field:   "body." + typeErr.Field,
+			Message: "must be " + describeKind(typeErr.Type),
+		}
+	}
+	return FieldError{Field: "body", Message: "invalid JSON: " + err.Error()}
+}
+
+// bindTagged sets path, query and header tagged fields of the struct v.
+func bindTagged(v reflect.Value, r *http.Request, vars map[string]string) []FieldError {
+	var fieldErrs []FieldError
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		field := v.Field(i)
+
+		if sf.Anonymous && field.Kind() == reflect.Struct {
+			fieldErrs = append(fieldErrs, bindTagged(field, r, vars)...)
+			continue
+		}
+		if !field.CanSet() {
+			continue
+		}
+
+		var source, name string
+		var values []string
+		if name = sf.Tag.Get("path"); name != "" {
+			source = "path"
+			if value, ok := vars[name]; ok {
+				values = []string{value}
+			}
+		} else if name = sf.Tag.Get("query"); name != "" {
+			source = "query"
+			values = r.URL.Query()[name]
+		} else if name = sf.Tag.Get("header"); name != "" {
+			source = "header"
+			values = r.Header.Values(name)
+		} else {
+			continue
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+		if err := setFieldValues(field, values); err != nil {
+			fieldErrs = append(fieldErrs, FieldError{Field: source + "." + name, Message: err.Error()})
+		}
+	}
+
+	return fieldErrs
+}
+
+// taggedFields returns the settable path, query and header tagged fields of
+// the struct v, including those of embedded structs.
+func taggedFields(v reflect.Value) []reflect.Value {
+	var fields []reflect.Value
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		field := v.Field(i)
+		if sf.Anonymous && field.Kind() == reflect.Struct {
+			fields = append(fields, taggedFields(field)...)
+			continue
+		}
+		if field.CanSet() && (sf.Tag.Get("path") != "" || sf.Tag.Get("query") != "" || sf.Tag.Get("header") != "") {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// setFieldValues sets field from one or more string values.
+// Slices take every value; other kinds take the first.
+func setFieldValues(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice && !implementsTextUnmarshaler(field) {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setFieldValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return setFieldValue(field, values[0])
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+func implementsTextUnmarshaler(field reflect.Value) bool {
+	return reflect.PointerTo(field.Type()).Implements(textUnmarshalerType)
+}
+
+// setFieldValue parses value into field according to its type.
+func setFieldValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Ptr {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setFieldValue(ptr.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	if implementsTextUnmarshaler(field) {
+		if err := field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("must be a valid %s", field.Type().Name())
+		}
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		field.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		field.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a non-negative integer")
+		}
+		field.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		field.SetFloat(f)
+
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// describeKind names a type for error messages, e.g. "an integer".
+func describeKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a " + strings.ToLower(t.Kind().String())
+}
@@ -0,0 +1,243 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const claimsKey contextKey = "claims"
+
+// TokenConfig describes how tokens are signed and validated. The same
+// config is typically passed to GenerateToken, ValidateToken and
+// RequireAuthWithConfig.
+type TokenConfig struct {
+	// Secret signs and verifies HS256 tokens. Ignored if Keys is set.
+	Secret string
+	// Keys verifies asymmetrically signed tokens. A *KeySet also signs them.
+	Keys KeyProvider
+
+	// Issuer is written to tokens without an "iss" claim, and required to
+	// match on validation if set.
+	Issuer string
+	// Audience is written to tokens without an "aud" claim. On validation,
+	// the token's audience must contain at least one of these if set.
+	Audience []string
+
+	// Leeway allows for clock skew when checking exp, nbf and iat.
+	Leeway time.Duration
+	// RequiredClaims lists claims a token must contain, e.g. "exp" or "tenant_id".
+	RequiredClaims []string
+
+	// mfaPending accepts only MFA pending tokens instead of refusing them.
+	mfaPending bool
+}
+
+// NewRegisteredClaims returns the standard claims of a token for userID:
+// a random token ID (jti), subject, issued at and expiration. Embed them
+// in custom claim structs.
+func NewRegisteredClaims(userID string, expiration time.Duration) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		ID:        rand.Text(),
+		Subject:   userID,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiration)),
+	}
+}
+
+// GenerateToken signs a token carrying claims, a struct that embeds
+// jwt.RegisteredClaims. The issuer and audience of cfg, the current time
+// as "iat" and a random "jti" are added if claims do not set them.
+//
+// Example:
+//
+//	type UserClaims struct {
+//	    jwt.RegisteredClaims
+//	    TenantID string   `json:"tenant_id"`
+//	    Roles    []string `json:"roles"`
+//	}
+//
+//	var tokens = bedrock.TokenConfig{
+//	    Secret:   secret,
+//	    Issuer:   "https://auth.example.com",
+//	    Audience: []string{"bookings"},
+//	}
+//
+//	token, err := bedrock.GenerateToken(UserClaims{
+//	    RegisteredClaims: bedrock.NewRegisteredClaims(user.ID, time.Hour),
+//	    TenantID:         user.TenantID,
+//	    Roles:            user.Roles,
+//	}, tokens)
+func GenerateToken[C jwt.Claims](claims C, cfg TokenConfig) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	merged := jwt.MapClaims{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber() // keep large integers exact
+	if err := decoder.Decode(&merged); err != nil {
+		return "", fmt.Errorf("bedrock: claims must encode to a JSON object: %w", err)
+	}
+
+	setDefault := func(name string, value any) {
+		if _, ok := merged[name]; !ok {
+			merged[name] = value
+		}
+	}
+	if cfg.Issuer != "" {
+		setDefault("iss", cfg.Issuer)
+	}
+	if len(cfg.Audience) > 0 {
+		setDefault("aud", jwt.ClaimStrings(cfg.Audience))
+	}
+	setDefault("iat", jwt.NewNumericDate(time.Now()))
+	setDefault("jti", rand.Text())
+
+	return cfg.sign(merged)
+}
+
+// sign signs claims with cfg.Keys if it is a *KeySet, or cfg.Secret.
+func (cfg TokenConfig) sign(claims jwt.Claims) (string, error) {
+	if cfg.Keys != nil {
+		keys, ok := cfg.Keys.(*KeySet)
+		if !ok {
+			return "", fmt.Errorf("bedrock: cannot sign with %T, use a *KeySet", cfg.Keys)
+		}
+		return keys.Sign(claims)
+	}
+	if cfg.Secret == "" {
+		return "", errors.New("bedrock: TokenConfig needs a Secret or Keys")
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Secret))
+}
+
+// ValidateToken verifies a token according to cfg and decodes its claims
+// into C, a struct that embeds jwt.RegisteredClaims.
+//
+// Example:
+//
+//	claims, err := bedrock.ValidateToken[UserClaims](ctx, token, tokens)
+//	if err != nil {
+//	    return bedrock.Unauthorized("invalid token")
+//	}
+func ValidateToken[C any, PC interface {
+	*C
+	jwt.Claims
+}](ctx context.Context, tokenString string, cfg TokenConfig) (C, error) {
+	var claims C
+	if _, err := parseToken(ctx, tokenString, PC(&claims), cfg); err != nil {
+		var zero C
+		return zero, err
+	}
+	return claims, nil
+}
+
+// hmacAlgs are the algorithms accepted for tokens signed with a secret.
+var hmacAlgs = []string{"HS256", "HS384", "HS512"}
+
+// parseToken verifies tokenString according to cfg, decodes its claims into
+// claims and returns the raw claims JSON.
+func parseToken(ctx context.Context, tokenString string, claims jwt.Claims, cfg TokenConfig) (json.RawMessage, error) {
+	var keyfunc jwt.Keyfunc
+	options := []jwt.ParserOption{jwt.WithLeeway(cfg.Leeway)}
+
+	switch {
+	case cfg.Keys != nil:
+		keyfunc = func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return cfg.Keys.VerificationKey(ctx, kid, token.Method.Alg())
+		}
+		options = append(options, jwt.WithValidMethods(asymmetricAlgs))
+	case cfg.Secret != "":
+		keyfunc = func(token *jwt.Token) (interface{}, error) {
+			return []byte(cfg.Secret), nil
+		}
+		options = append(options, jwt.WithValidMethods(hmacAlgs))
+	default:
+		return nil, errors.New("bedrock: TokenConfig needs a Secret or Keys")
+	}
+
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if len(cfg.Audience) > 0 {
+		options = append(options, jwt.WithAudience(cfg.Audience...))
+	}
+
+	parser := jwt.NewParser(options...)
+	token, err := parser.ParseWithClaims(tokenString, claims, keyfunc)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	// The signature is valid, so the payload is well-formed
+	payload, err := parser.DecodeSegment(strings.Split(tokenString, ".")[1])
+	if err != nil {
+		return nil, err
+	}
+
+	// MFA pending tokens only prove the first factor of a login
+	var mfa struct {
+		Pending bool `json:"mfa_pending"`
+	}
+	if err := json.Unmarshal(payload, &mfa); err != nil {
+		return nil, err
+	}
+	if mfa.Pending != cfg.mfaPending {
+		return nil, errors.New("token is not valid for this use")
+	}
+
+	if len(cfg.RequiredClaims) > 0 {
+		var present map[string]json.RawMessage
+		if err := json.Unmarshal(payload, &present); err != nil {
+			return nil, err
+		}
+		for _, name := range cfg.RequiredClaims {
+			if _, ok := present[name]; !ok {
+				return nil, fmt.Errorf("missing required claim %q", name)
+			}
+		}
+	}
+	return payload, nil
+}
+
+// withClaims stores the raw claims of the request's token in ctx.
+func withClaims(ctx context.Context, payload json.RawMessage) context.Context {
+	return context.WithValue(ctx, claimsKey, payload)
+}
+
+// GetClaims decodes the claims of the token that authenticated the request
+// into C. It returns false outside the auth middleware, or if the claims
+// do not fit C.
+//
+// Example:
+//
+//	func (a *MyApp) listBookings(ctx context.Context, r *http.Request) bedrock.Response {
+//	    claims, ok := bedrock.GetClaims[UserClaims](ctx)
+//	    if !ok {
+//	        return bedrock.Unauthorized("invalid claims")
+//	    }
+//	    bookings, err := a.db.ListBookings(ctx, claims.TenantID)
+//	    // ...
+//	}
+func GetClaims[C any](ctx context.Context) (C, bool) {
+	var claims C
+	payload, ok := ctx.Value(claimsKey).(json.RawMessage)
+	if !ok || json.Unmarshal(payload, &claims) != nil {
+		var zero C
+		return zero, false
+	}
+	return claims, true
+}
@@ -0,0 +1,177 @@
+package bedrock
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type userClaims struct {
+	jwt.RegisteredClaims
+	TenantID string   `json:"tenant_id"`
+	Roles    []string `json:"roles,omitempty"`
+	Number   int64    `json:"number,omitempty"`
+}
+
+var testTokenConfig = TokenConfig{
+	Secret:   "test-secret-key",
+	Issuer:   "https://auth.example.com",
+	Audience: []string{"bookings"},
+}
+
+func TestGenerateAndValidateToken(t *testing.T) {
+	claims := userClaims{
+		RegisteredClaims: NewRegisteredClaims("user123", time.Hour),
+		TenantID:         "acme",
+		Roles:            []string{"admin"},
+		Number:           1<<62 + 1, // not representable as a float64
+	}
+	token, err := GenerateToken(claims, testTokenConfig)
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	got, err := ValidateToken[userClaims](context.Background(), token, testTokenConfig)
+	if err != nil {
+		t.Fatalf("ValidateToken failed: %v", err)
+	}
+	if got.Subject != "user123" || got.TenantID != "acme" || !reflect.DeepEqual(got.Roles, []string{"admin"}) || got.Number != claims.Number {
+		t.Errorf("unexpected claims: %+v", got)
+	}
+	// Issuer and audience come from the config
+	if got.Issuer != testTokenConfig.Issuer || !reflect.DeepEqual([]string(got.Audience), testTokenConfig.Audience) {
+		t.Errorf("expected iss and aud from config, got %q %v", got.Issuer, got.Audience)
+	}
+}
+
+func TestGenerateToken_WithKeySet(t *testing.T) {
+	keys := NewKeySet()
+	keys.AddSigningKey("k1", testSigners(t)["ES256"])
+	cfg := TokenConfig{Keys: keys}
+
+	token, err := GenerateToken(userClaims{RegisteredClaims: NewRegisteredClaims("user123", time.Hour), TenantID: "acme"}, cfg)
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	got, err := ValidateToken[userClaims](context.Background(), token, cfg)
+	if err != nil || got.TenantID != "acme" {
+		t.Errorf("got %+v, %v", got, err)
+	}
+
+	if _, err := GenerateToken(NewRegisteredClaims("user123", time.Hour), TokenConfig{Keys: NewRemoteKeySet("http://unused", 0)}); err == nil {
+		t.Error("expected error signing with a RemoteKeySet")
+	}
+}
+
+func TestValidateToken_Rejects(t *testing.T) {
+	ctx := context.Background()
+	sign := func(claims userClaims, cfg TokenConfig) string {
+		t.Helper()
+		token, err := GenerateToken(claims, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := userClaims{RegisteredClaims: NewRegisteredClaims("user123", time.Hour)}
+
+	otherIssuer := testTokenConfig
+	otherIssuer.Issuer = "https://evil.example.com"
+	otherAudience := testTokenConfig
+	otherAudience.Audience = []string{"billing"}
+	required := testTokenConfig
+	required.RequiredClaims = []string{"exp", "roles"}
+	noExp := valid
+	noExp.ExpiresAt = nil
+
+	tests := []struct {
+		name  string
+		token string
+		cfg   TokenConfig
+	}{
+		{"wrong issuer", sign(valid, otherIssuer), testTokenConfig},
+		{"wrong audience", sign(valid, otherAudience), testTokenConfig},
+		{"missing roles", sign(valid, testTokenConfig), required},
+		{"missing exp", sign(noExp, testTokenConfig), required},
+		{"no secret or keys", sign(valid, testTokenConfig), TokenConfig{}},
+	}
+	for _, tt := range tests {
+		if _, err := ValidateToken[userClaims](ctx, tt.token, tt.cfg); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+
+	// Audience matches if the token contains any of the configured ones
+	multi := testTokenConfig
+	multi.Audience = []string{"billing", "bookings"}
+	if _, err := ValidateToken[userClaims](ctx, sign(valid, testTokenConfig), multi); err != nil {
+		t.Errorf("expected audience overlap to pass: %v", err)
+	}
+}
+
+func TestValidateToken_Leeway(t *testing.T) {
+	claims := userClaims{RegisteredClaims: NewRegisteredClaims("user123", -10*time.Second)}
+	token, _ := GenerateToken(claims, testTokenConfig)
+
+	if _, err := ValidateToken[userClaims](context.Background(), token, testTokenConfig); err == nil {
+		t.Error("expected expired token to be rejected without leeway")
+	}
+	lenient := testTokenConfig
+	lenient.Leeway = time.Minute
+	if _, err := ValidateToken[userClaims](context.Background(), token, lenient); err != nil {
+		t.Errorf("expected token within leeway to pass: %v", err)
+	}
+}
+
+func TestRequireAuthWithConfig_GetClaims(t *testing.T) {
+	var got userClaims
+	var ok bool
+	handler := Chain(func(ctx context.Context, r *http.Request) Response {
+		got, ok = GetClaims[userClaims](ctx)
+		return JSON(200, nil)
+	}, RequireAuthWithConfig(testTokenConfig))
+
+	token, _ := GenerateToken(userClaims{RegisteredClaims: NewRegisteredClaims("user123", time.Hour), TenantID: "acme"}, testTokenConfig)
+	req := httptest.NewRequest("GET", "/bookings", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if resp := handler(context.Background(), req).(JSONResponse); resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if !ok || got.Subject != "user123" || got.TenantID != "acme" {
+		t.Errorf("unexpected claims: %+v, %v", got, ok)
+	}
+
+	// A valid token for another audience is refused
+	other := testTokenConfig
+	other.Audience = []string{"billing"}
+	token, _ = GenerateToken(NewRegisteredClaims("user123", time.Hour), other)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if resp := handler(context.Background(), req).(*HTTPError); resp.Status != 401 {
+		t.Errorf("expected 401 for wrong audience, got %d", resp.Status)
+	}
+}
+
+func TestGetClaims_WithoutAuth(t *testing.T) {
+	if _, ok := GetClaims[userClaims](context.Background()); ok {
+		t.Error("expected no claims outside the auth middleware")
+	}
+
+	// The plain RequireAuth middleware populates claims too
+	var got jwt.RegisteredClaims
+	handler := Chain(func(ctx context.Context, r *http.Request) Response {
+		got, _ = GetClaims[jwt.RegisteredClaims](ctx)
+		return JSON(200, nil)
+	}, RequireAuth("secret"))
+	token, _ := GenerateJWT("user123", "secret", time.Hour)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	handler(context.Background(), req)
+	if got.Subject != "user123" || got.ExpiresAt == nil {
+		t.Errorf("unexpected claims: %+v", got)
+	}
+}
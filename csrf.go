@@ -0,0 +1,241 @@
+package bedrock
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Defaults used by CSRF when the CSRFConfig fields are zero.
+const (
+	DefaultCSRFCookie = "csrf_token"
+	DefaultCSRFHeader = "X-CSRF-Token"
+	DefaultCSRFField  = "csrf_token"
+)
+
+const (
+	csrfTokenKey contextKey = "csrfToken"
+	corsKey      contextKey = "cors"
+)
+
+// csrfSessionKey is the session value holding the token when
+// CSRFConfig.UseSession is set.
+const csrfSessionKey = "_csrf"
+
+// CSRFConfig configures CSRF.
+type CSRFConfig struct {
+	// AllowedOrigins lists the origins, besides the request's own, that may
+	// send unsafe requests, e.g. "https://app.example.com". Optional;
+	// defaults to the AllowedOrigins of the route's CORS config. "*" is
+	// ignored: it would let any site through.
+	AllowedOrigins []string
+
+	// UseSession stores the token in the session (synchronizer token)
+	// instead of a cookie (double-submit cookie). It requires the Sessions
+	// middleware to run before CSRF.
+	UseSession bool
+
+	CookieName string        // optional; defaults to DefaultCSRFCookie
+	HeaderName string        // optional; defaults to DefaultCSRFHeader
+	FieldName  string        // optional; form field checked when the header is absent, defaults to DefaultCSRFField
+	Path       string        // optional; cookie path, defaults to "/"
+	Domain     string        // optional; cookie domain
+	SameSite   http.SameSite // optional; defaults to http.SameSiteLaxMode
+	Insecure   bool          // optional; omit the Secure cookie flag, for local development over HTTP
+}
+
+func (cfg CSRFConfig) withDefaults() CSRFConfig {
+	if cfg.CookieName == "" {
+		cfg.CookieName = DefaultCSRFCookie
+	}
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = DefaultCSRFHeader
+	}
+	if cfg.FieldName == "" {
+		cfg.FieldName = DefaultCSRFField
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+	return cfg
+}
+
+// CSRF creates middleware that protects cookie-authenticated routes, such
+// as those using Sessions, against cross-site request forgery.
+//
+// Every request gets a token, which handlers read with CSRFToken. Requests
+// with unsafe methods (anything but GET, HEAD, OPTIONS and TRACE) must send
+// it back in the X-CSRF-Token header or the csrf_token form field, and must
+// come from the request's own origin or an allowed one if they carry an
+// Origin or Referer header. Otherwise CSRF returns a 403.
+//
+// By default the token is kept in a cookie that scripts can read, so SPAs
+// can copy it into the header (double-submit cookie). With UseSession it is
+// kept in the session instead (synchronizer token).
+//
+// Requests that a token or API key middleware running before CSRF
+// authenticated, such as RequireAuth, are not checked: browsers never
+// attach those credentials on their own, so they cannot be forged. With
+// CSRF in Options.Middleware, which runs before route middleware, every
+// unsafe request is checked; to exempt API clients, add CSRF to the route
+// or group middleware after the auth middleware instead.
+//
+// Usage:
+//
+//	sessions := bedrock.Sessions(bedrock.SessionConfig{Store: store})
+//	csrf := bedrock.CSRF(bedrock.CSRFConfig{})
+//	opts := bedrock.Options{Middleware: []bedrock.Middleware{sessions, csrf}}
+//
+//	// In a template: <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+//	data := page{CSRFToken: bedrock.CSRFToken(ctx)}
+func CSRF(cfg CSRFConfig) Middleware {
+	cfg = cfg.withDefaults()
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, r *http.Request) Response {
+			// Only set by header credentials, never by Sessions
+			if _, ok := ctx.Value(claimsKey).(json.RawMessage); ok {
+				return next(ctx, r)
+			}
+
+			token, issued := cfg.token(ctx, r)
+			ctx = context.WithValue(ctx, csrfTokenKey, token)
+
+			response := cfg.check(ctx, r, token, issued)
+			if response == nil {
+				response = next(ctx, r)
+			}
+			// Set the cookie even on failure, so the client can retry
+			if issued && !cfg.UseSession {
+				return cookieResponse{Response: response, cookie: cfg.cookie(token)}
+			}
+			return response
+		}
+	}
+}
+
+// check returns an error response if an unsafe request fails the origin
+// or token check, and nil otherwise.
+func (cfg CSRFConfig) check(ctx context.Context, r *http.Request, token string, issued bool) Response {
+	if safeMethod(r.Method) {
+		return nil
+	}
+	if !cfg.sameOrigin(ctx, r) {
+		Logger(ctx).Warn("CSRF check failed", "reason", "origin",
+			"origin", r.Header.Get("Origin"), "referer", r.Header.Get("Referer"))
+		return Forbidden("cross-origin request rejected")
+	}
+
+	sent := r.Header.Get(cfg.HeaderName)
+	if sent == "" && isForm(r) {
+		sent = r.PostFormValue(cfg.FieldName)
+	}
+	// A token issued by this request cannot have been sent with it
+	if issued || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+		Logger(ctx).Warn("CSRF check failed", "reason", "token")
+		return Forbidden("invalid CSRF token")
+	}
+	return nil
+}
+
+// token returns the request's CSRF token, and whether it was just issued.
+func (cfg CSRFConfig) token(ctx context.Context, r *http.Request) (string, bool) {
+	if cfg.UseSession {
+		session := GetSession(ctx)
+		if token := session.Get(csrfSessionKey); token != "" {
+			return token, false
+		}
+		token := rand.Text()
+		session.Set(csrfSessionKey, token)
+		return token, true
+	}
+
+	if c, err := r.Cookie(cfg.CookieName); err == nil && c.Value != "" {
+		return c.Value, false
+	}
+	return rand.Text(), true
+}
+
+// sameOrigin reports whether the request comes from its own origin or an
+// allowed one. Requests without Origin and Referer headers pass, and rely
+// on the token check alone.
+func (cfg CSRFConfig) sameOrigin(ctx context.Context, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		referer, err := url.Parse(r.Header.Get("Referer"))
+		if err != nil || referer.Host == "" {
+			return r.Header.Get("Referer") == ""
+		}
+		origin = referer.Scheme + "://" + referer.Host
+	}
+
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && u.Host == r.Host {
+		return true
+	}
+
+	allowed := cfg.AllowedOrigins
+	if allowed == nil {
+		if cors, ok := ctx.Value(corsKey).(*CORSConfig); ok {
+			allowed = cors.AllowedOrigins
+		}
+	}
+	for _, o := range allowed {
+		if o != "*" && o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// cookie returns the double-submit cookie. It is not HttpOnly, so scripts
+// on the page can read the token.
+func (cfg CSRFConfig) cookie(token string) *http.Cookie {
+	return &http.Cookie{
+		Name:     cfg.CookieName,
+		Value:    token,
+		Path:     cfg.Path,
+		Domain:   cfg.Domain,
+		Secure:   !cfg.Insecure,
+		SameSite: cfg.SameSite,
+	}
+}
+
+// CSRFToken returns the request's CSRF token, to embed in forms or send to
+// SPAs, or "" outside the CSRF middleware.
+//
+// Example:
+//
+//	func (a *MyApp) csrfToken(ctx context.Context, r *http.Request) bedrock.Response {
+//	    return bedrock.JSON(200, map[string]string{"csrf_token": bedrock.CSRFToken(ctx)})
+//	}
+func CSRFToken(ctx context.Context) string {
+	token, _ := ctx.Value(csrfTokenKey).(string)
+	return token
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func isForm(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return strings.HasPrefix(contentType, "application/x-www-form-urlencoded") ||
+		strings.HasPrefix(contentType, "multipart/form-data")
+}
+
+// withCORSConfig records the CORS config of the route, whose allowed
+// origins CSRF also trusts.
+func withCORSConfig(ctx context.Context, cors *CORSConfig) context.Context {
+	return context.WithValue(ctx, corsKey, cors)
+}
@@ -0,0 +1,216 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func csrfHandler(cfg CSRFConfig) Handler {
+	return Chain(func(ctx context.Context, r *http.Request) Response {
+		return JSON(200, map[string]string{"csrf_token": CSRFToken(ctx)})
+	}, CSRF(cfg))
+}
+
+func serveCSRF(handler Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	handler(context.Background(), req).Write(context.Background(), w)
+	return w
+}
+
+func TestCSRF_DoubleSubmit(t *testing.T) {
+	handler := csrfHandler(CSRFConfig{})
+
+	// A safe request issues the token in a cookie scripts can read
+	w := serveCSRF(handler, httptest.NewRequest("GET", "/", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != DefaultCSRFCookie || cookies[0].HttpOnly || !cookies[0].Secure {
+		t.Fatalf("unexpected cookies: %+v", cookies)
+	}
+	token := cookies[0].Value
+	if body := decodeBody(t, w); body["csrf_token"] != token {
+		t.Errorf("expected CSRFToken to return the cookie value, got %v", body)
+	}
+
+	post := func(header, field string) int {
+		var req *http.Request
+		if field != "" {
+			form := url.Values{DefaultCSRFField: {field}}
+			req = httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest("POST", "/", nil)
+		}
+		req.AddCookie(cookies[0])
+		if header != "" {
+			req.Header.Set(DefaultCSRFHeader, header)
+		}
+		return serveCSRF(handler, req).Code
+	}
+
+	if code := post(token, ""); code != 200 {
+		t.Errorf("expected 200 with token in header, got %d", code)
+	}
+	if code := post("", token); code != 200 {
+		t.Errorf("expected 200 with token in form field, got %d", code)
+	}
+	if code := post("", ""); code != 403 {
+		t.Errorf("expected 403 without token, got %d", code)
+	}
+	if code := post("forged", ""); code != 403 {
+		t.Errorf("expected 403 with wrong token, got %d", code)
+	}
+
+	// Without the cookie, a new token is issued and the request rejected
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Header.Set(DefaultCSRFHeader, token)
+	w = serveCSRF(handler, req)
+	if w.Code != 403 || len(w.Result().Cookies()) != 1 {
+		t.Errorf("expected 403 and a new cookie, got %d %v", w.Code, w.Result().Cookies())
+	}
+}
+
+func TestCSRF_Exemptions(t *testing.T) {
+	handler := csrfHandler(CSRFConfig{})
+
+	for _, method := range []string{"GET", "HEAD", "OPTIONS", "TRACE"} {
+		if code := serveCSRF(handler, httptest.NewRequest(method, "/", nil)).Code; code != 200 {
+			t.Errorf("%s: expected 200, got %d", method, code)
+		}
+	}
+
+	// A bearer header alone is no exemption
+	req := httptest.NewRequest("DELETE", "/", nil)
+	req.Header.Set("Authorization", "Bearer abc")
+	if code := serveCSRF(handler, req).Code; code != 403 {
+		t.Errorf("expected unauthenticated bearer request to be checked, got %d", code)
+	}
+
+	// Requests the auth middleware authenticated are not checked
+	secret := "test-secret"
+	authenticated := Chain(func(ctx context.Context, r *http.Request) Response {
+		return JSON(200, nil)
+	}, RequireAuth(secret), CSRF(CSRFConfig{}))
+	token, _ := GenerateJWT("user123", secret, time.Hour)
+	req = httptest.NewRequest("DELETE", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := serveCSRF(authenticated, req)
+	if w.Code != 200 || len(w.Result().Cookies()) != 0 {
+		t.Errorf("expected bearer request to skip CSRF, got %d %v", w.Code, w.Result().Cookies())
+	}
+}
+
+func TestCSRF_Origin(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		referer string
+		want    int
+	}{
+		{"same origin", nil, "https://example.com", "", 200},
+		{"no origin or referer", nil, "", "", 200},
+		{"cross origin", nil, "https://evil.test", "", 403},
+		{"cross origin referer", nil, "", "https://evil.test/form", 403},
+		{"same origin referer", nil, "", "https://example.com/form", 200},
+		{"null origin", nil, "null", "", 403},
+		{"allowed origin", []string{"https://app.example.org"}, "https://app.example.org", "", 200},
+		{"wildcard ignored", []string{"*"}, "https://evil.test", "", 403},
+	}
+	for _, tt := range tests {
+		handler := csrfHandler(CSRFConfig{AllowedOrigins: tt.allowed})
+		req := httptest.NewRequest("POST", "https://example.com/", nil)
+		req.AddCookie(&http.Cookie{Name: DefaultCSRFCookie, Value: "token"})
+		req.Header.Set(DefaultCSRFHeader, "token")
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.referer != "" {
+			req.Header.Set("Referer", tt.referer)
+		}
+		if code := serveCSRF(handler, req).Code; code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, code)
+		}
+	}
+}
+
+func TestCSRF_CORSOrigins(t *testing.T) {
+	group := Group("/api", CSRF(CSRFConfig{})).
+		CORS(CORSConfig{AllowedOrigins: []string{"https://admin.example.org"}}).
+		Add(Route{Method: "POST", Path: "/items", Handler: func(ctx context.Context, r *http.Request) Response {
+			return JSON(200, nil)
+		}})
+
+	var buf bytes.Buffer
+	router := testRouter(group.Routes(), Options{
+		CORS: &CORSConfig{AllowedOrigins: []string{"https://app.example.org"}},
+	}, &buf)
+
+	for origin, want := range map[string]int{
+		"https://admin.example.org": 200,
+		"https://app.example.org":   403, // replaced by the group's config
+	} {
+		req := httptest.NewRequest("POST", "/api/items", nil)
+		req.AddCookie(&http.Cookie{Name: DefaultCSRFCookie, Value: "token"})
+		req.Header.Set(DefaultCSRFHeader, "token")
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", origin, want, w.Code)
+		}
+	}
+	if !strings.Contains(buf.String(), "CSRF check failed") {
+		t.Error("expected rejected request to be logged")
+	}
+}
+
+func TestCSRF_Session(t *testing.T) {
+	store := NewMemorySessionStore()
+	handler := Chain(func(ctx context.Context, r *http.Request) Response {
+		if r.URL.Path == "/login" {
+			GetSession(ctx).Login("user123")
+		}
+		return JSON(200, map[string]string{"csrf_token": CSRFToken(ctx)})
+	}, Sessions(SessionConfig{Store: store}), CSRF(CSRFConfig{UseSession: true}))
+
+	client := &sessionClient{t: t, handler: handler}
+	token, _ := decodeBody(t, client.do("/"))["csrf_token"].(string)
+	if token == "" || client.cookie == nil || client.cookie.Name != DefaultSessionCookie {
+		t.Fatalf("expected the token to be stored in a new session, got %q %+v", token, client.cookie)
+	}
+	if again := decodeBody(t, client.do("/"))["csrf_token"]; again != token {
+		t.Errorf("expected a stable token, got %q then %q", token, again)
+	}
+
+	post := func(sent string) int {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.AddCookie(client.cookie)
+		req.Header.Set(DefaultCSRFHeader, sent)
+		return serveCSRF(handler, req).Code
+	}
+	if code := post(token); code != 200 {
+		t.Errorf("expected 200 with the session token, got %d", code)
+	}
+	if code := post("forged"); code != 403 {
+		t.Errorf("expected 403 with wrong token, got %d", code)
+	}
+
+	// Logging in rotates the token
+	client.do("/login")
+	rotated, _ := decodeBody(t, client.do("/"))["csrf_token"].(string)
+	if rotated == "" || rotated == token {
+		t.Errorf("expected a new token after login, got %q", rotated)
+	}
+	if code := post(token); code != 403 {
+		t.Errorf("expected the pre-login token to be rejected, got %d", code)
+	}
+	if code := post(rotated); code != 200 {
+		t.Errorf("expected 200 with the new token, got %d", code)
+	}
+}
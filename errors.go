@@ -0,0 +1,290 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// HTTPError is an error that knows which HTTP response it should produce.
+// It implements both error and Response, so handlers can return it directly.
+//
+// By default it is written as JSON:
+//
+//	{"error": "booking not found", "code": "not_found", "request_id": "..."}
+//
+// With Options.ProblemDetails it is written as RFC 7807 application/problem+json:
+//
+//	{"type": "about:blank", "title": "Not Found", "status": 404, "detail": "booking not found", "code": "not_found"}
+//
+// Example:
+//
+//	if booking == nil {
+//	    return bedrock.NotFound("booking not found")
+//	}
+type HTTPError struct {
+	Status  int    // HTTP status code
+	Code    string // machine-readable code, e.g. "not_found"; defaults from Status
+	Message string // human-readable message, safe to show to clients
+	Details any    // optional structured details, e.g. per-field validation errors
+	Type    string // optional problem type URI; defaults to "about:blank"
+	Err     error  // optional underlying cause; never sent to clients
+}
+
+// NewHTTPError creates an HTTPError. If code is empty it is derived from the
+// status text, e.g. 404 becomes "not_found".
+func NewHTTPError(status int, code, message string) *HTTPError {
+	if code == "" {
+		code = statusCode(status)
+	}
+	return &HTTPError{Status: status, Code: code, Message: message}
+}
+
+// BadRequest returns a 400 HTTPError.
+func BadRequest(message string) *HTTPError {
+	return NewHTTPError(http.StatusBadRequest, "", message)
+}
+
+// Unauthorized returns a 401 HTTPError.
+func Unauthorized(message string) *HTTPError {
+	return NewHTTPError(http.StatusUnauthorized, "", message)
+}
+
+// Forbidden returns a 403 HTTPError.
+func Forbidden(message string) *HTTPError {
+	return NewHTTPError(http.StatusForbidden, "", message)
+}
+
+// NotFound returns a 404 HTTPError.
+func NotFound(message string) *HTTPError {
+	return NewHTTPError(http.StatusNotFound, "", message)
+}
+
+// Conflict returns a 409 HTTPError.
+func Conflict(message string) *HTTPError {
+	return NewHTTPError(http.StatusConflict, "", message)
+}
+
+// UnprocessableEntity returns a 422 HTTPError.
+func UnprocessableEntity(message string) *HTTPError {
+	return NewHTTPError(http.StatusUnprocessableEntity, "", message)
+}
+
+// InternalError returns a 500 HTTPError with a generic message.
+func InternalError() *HTTPError {
+	return NewHTTPError(http.StatusInternalServerError, "", "internal server error")
+}
+
+// WithDetails returns a copy of e with Details set.
+func (e *HTTPError) WithDetails(details any) *HTTPError {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+// Wrap returns a copy of e with err recorded as its cause.
+func (e *HTTPError) Wrap(err error) *HTTPError {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// Write writes e as JSON, or as application/problem+json if
+// Options.ProblemDetails is enabled. The request ID is included when known.
+// A zero Status is written as 500, and the cause of a 5xx error is logged
+// with the request's logger.
+func (e *HTTPError) Write(ctx context.Context, w http.ResponseWriter) error {
+	status := e.Status
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	if status >= 500 && e.Err != nil {
+		Logger(ctx).Error("request failed", "err", e.Err)
+	}
+
+	code := e.Code
+	if code == "" {
+		code = statusCode(status)
+	}
+	requestID, hasRequestID := GetRequestID(ctx)
+
+	body := map[string]any{"code": code}
+	if e.Details != nil {
+		body["details"] = e.Details
+	}
+	if hasRequestID {
+		body["request_id"] = requestID
+	}
+
+	if errorConfigFrom(ctx).problemDetails {
+		problemType := e.Type
+		if problemType == "" {
+			problemType = "about:blank"
+		}
+		body["type"] = problemType
+		body["title"] = http.StatusText(status)
+		body["status"] = status
+		if e.Message != "" {
+			body["detail"] = e.Message
+		}
+		w.Header().Set("Content-Type", "application/problem+json")
+	} else {
+		body["error"] = e.Message
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(body)
+}
+
+// statusCode derives a machine-readable code from a status, e.g. "method_not_allowed".
+func statusCode(status int) string {
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	text = strings.ToLower(text)
+	text = strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(text)
+	return text
+}
+
+// --- Error mapping ---
+
+// ErrorMapper converts domain errors into HTTPErrors.
+// Mappings are tried in registration order; the first match wins.
+//
+// Example:
+//
+//	bedrock.DefaultErrorMapper.Register(sql.ErrNoRows, http.StatusNotFound, "not_found", "resource not found")
+//	bedrock.MapErrorType(bedrock.DefaultErrorMapper, func(err *ValidationError) *bedrock.HTTPError {
+//	    return bedrock.UnprocessableEntity(err.Error())
+//	})
+type ErrorMapper struct {
+	mu       sync.RWMutex
+	mappings []func(err error) *HTTPError
+}
+
+// DefaultErrorMapper is the mapper used when Options.Errors is nil.
+var DefaultErrorMapper = NewErrorMapper()
+
+// NewErrorMapper creates an empty ErrorMapper.
+func NewErrorMapper() *ErrorMapper {
+	return &ErrorMapper{}
+}
+
+// Register maps any error matching target (via errors.Is) to a fixed HTTPError.
+func (m *ErrorMapper) Register(target error, status int, code, message string) {
+	m.RegisterFunc(func(err error) *HTTPError {
+		if errors.Is(err, target) {
+			return NewHTTPError(status, code, message).Wrap(err)
+		}
+		return nil
+	})
+}
+
+// RegisterFunc adds a mapping function. It returns nil if it doesn't handle err.
+func (m *ErrorMapper) RegisterFunc(fn func(err error) *HTTPError) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mappings = append(m.mappings, fn)
+}
+
+// MapErrorType maps any error in the chain of type T (via errors.As) using fn.
+func MapErrorType[T error](m *ErrorMapper, fn func(T) *HTTPError) {
+	m.RegisterFunc(func(err error) *HTTPError {
+		var target T
+		if errors.As(err, &target) {
+			return fn(target)
+		}
+		return nil
+	})
+}
+
+// Map converts err into an HTTPError. An *HTTPError in the chain is returned
+// as is and a *ParamError becomes a 400; otherwise the registered mappings
+// are tried. Unmapped errors become a generic 500 that wraps err.
+func (m *ErrorMapper) Map(err error) *HTTPError {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	var paramErr *ParamError
+	if errors.As(err, &paramErr) {
+		return paramErr.httpError()
+	}
+
+	m.mu.RLock()
+	mappings := m.mappings
+	m.mu.RUnlock()
+
+	for _, fn := range mappings {
+		if mapped := fn(err); mapped != nil {
+			return mapped
+		}
+	}
+	return InternalError().Wrap(err)
+}
+
+// FromError returns a Response for err using the configured ErrorMapper.
+// Unmapped errors produce a generic 500 and are logged with the request's logger,
+// so internal details never reach the client.
+//
+// Example:
+//
+//	booking, err := a.store.Get(ctx, id)
+//	if err != nil {
+//	    return bedrock.FromError(err) // sql.ErrNoRows -> 404 if registered
+//	}
+func FromError(err error) Response {
+	return errorResponse{err: err}
+}
+
+// errorResponse defers mapping until Write, when the ErrorMapper configured
+// for the running app is available from the context.
+type errorResponse struct {
+	err error
+}
+
+func (r errorResponse) Write(ctx context.Context, w http.ResponseWriter) error {
+	return errorConfigFrom(ctx).mapper.Map(r.err).Write(ctx, w)
+}
+
+// errorConfig controls how errors are rendered for a request.
+type errorConfig struct {
+	mapper         *ErrorMapper
+	problemDetails bool
+}
+
+const errorConfigKey contextKey = "errorConfig"
+
+func withErrorConfig(ctx context.Context, cfg errorConfig) context.Context {
+	return context.WithValue(ctx, errorConfigKey, cfg)
+}
+
+func errorConfigFrom(ctx context.Context) errorConfig {
+	if cfg, ok := ctx.Value(errorConfigKey).(errorConfig); ok {
+		return cfg
+	}
+	return errorConfig{mapper: DefaultErrorMapper}
+}
+
+// routerErrorHandler serves err for requests that matched no route, such as 404 and 405.
+func routerErrorHandler(cfg errorConfig, err *HTTPError) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err.Write(withErrorConfig(r.Context(), cfg), w)
+	})
+}
@@ -0,0 +1,223 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var body map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", w.Body.String(), err)
+	}
+	return body
+}
+
+func TestHTTPError_JSON(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "req-1")
+	w := httptest.NewRecorder()
+
+	err := NotFound("booking not found").WithDetails(map[string]string{"id": "42"})
+	if writeErr := err.Write(ctx, w); writeErr != nil {
+		t.Fatalf("Write failed: %v", writeErr)
+	}
+
+	if w.Code != 404 {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type: got %q", got)
+	}
+	body := decodeBody(t, w)
+	if body["error"] != "booking not found" || body["code"] != "not_found" || body["request_id"] != "req-1" {
+		t.Errorf("unexpected body: %v", body)
+	}
+	if details, _ := body["details"].(map[string]any); details["id"] != "42" {
+		t.Errorf("unexpected details: %v", body["details"])
+	}
+}
+
+func TestHTTPError_ProblemDetails(t *testing.T) {
+	ctx := withErrorConfig(context.Background(), errorConfig{mapper: NewErrorMapper(), problemDetails: true})
+	w := httptest.NewRecorder()
+
+	err := NewHTTPError(http.StatusConflict, "slot_taken", "the slot is already booked")
+	err.Type = "https://example.com/problems/slot-taken"
+	err.Write(ctx, w)
+
+	if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+		t.Errorf("Content-Type: got %q", got)
+	}
+	body := decodeBody(t, w)
+	want := map[string]any{
+		"type":   "https://example.com/problems/slot-taken",
+		"title":  "Conflict",
+		"status": float64(409),
+		"detail": "the slot is already booked",
+		"code":   "slot_taken",
+	}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("%s: got %v, want %v", key, body[key], value)
+		}
+	}
+	if _, ok := body["error"]; ok {
+		t.Error("problem details should not include the JSON-mode error field")
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	cases := map[int]string{
+		404: "not_found",
+		405: "method_not_allowed",
+		418: "im_a_teapot",
+		999: "error",
+	}
+	for status, want := range cases {
+		if got := statusCode(status); got != want {
+			t.Errorf("statusCode(%d): got %q, want %q", status, got, want)
+		}
+	}
+}
+
+type quotaError struct{ limit int }
+
+func (e *quotaError) Error() string { return fmt.Sprintf("quota of %d exceeded", e.limit) }
+
+func TestErrorMapper(t *testing.T) {
+	mapper := NewErrorMapper()
+	mapper.Register(sql.ErrNoRows, http.StatusNotFound, "", "resource not found")
+	MapErrorType(mapper, func(err *quotaError) *HTTPError {
+		return NewHTTPError(http.StatusTooManyRequests, "quota_exceeded", err.Error())
+	})
+
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"sentinel", sql.ErrNoRows, 404, "not_found"},
+		{"wrapped sentinel", fmt.Errorf("loading booking: %w", sql.ErrNoRows), 404, "not_found"},
+		{"typed", fmt.Errorf("send: %w", &quotaError{limit: 5}), 429, "quota_exceeded"},
+		{"http error", fmt.Errorf("wrapped: %w", Forbidden("nope")), 403, "forbidden"},
+		{"unmapped", errors.New("disk on fire"), 500, "internal_server_error"},
+	}
+	for _, tc := range cases {
+		got := mapper.Map(tc.err)
+		if got.Status != tc.status || got.Code != tc.code {
+			t.Errorf("%s: got %d %q, want %d %q", tc.name, got.Status, got.Code, tc.status, tc.code)
+		}
+	}
+}
+
+func TestFromError_HidesInternalDetails(t *testing.T) {
+	var buf bytes.Buffer
+	routes := []Route{{
+		Method: "GET",
+		Path:   "/fail",
+		Handler: func(ctx context.Context, r *http.Request) Response {
+			return FromError(errors.New("pq: password authentication failed"))
+		},
+	}}
+	router := testRouter(routes, Options{Errors: NewErrorMapper()}, &buf)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/fail", nil))
+
+	body := decodeBody(t, w)
+	if w.Code != 500 || body["error"] != "internal server error" {
+		t.Errorf("expected generic 500, got %d %v", w.Code, body)
+	}
+
+	lines := logLines(t, &buf)
+	if lines[0]["msg"] != "request failed" || lines[0]["err"] != "pq: password authentication failed" {
+		t.Errorf("underlying error should be logged, got %v", lines[0])
+	}
+}
+
+func TestHTTPError_LogsServerErrors(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := WithLogger(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	InternalError().Wrap(errors.New("disk full")).Write(ctx, httptest.NewRecorder())
+	lines := logLines(t, &buf)
+	if len(lines) != 1 || lines[0]["msg"] != "request failed" || lines[0]["err"] != "disk full" {
+		t.Errorf("expected the cause of a 500 to be logged, got %v", lines)
+	}
+
+	// Client errors are not logged
+	buf.Reset()
+	BadRequest("invalid date").Wrap(errors.New("parsing time")).Write(ctx, httptest.NewRecorder())
+	if buf.Len() != 0 {
+		t.Errorf("expected no log for a 400, got %s", buf.String())
+	}
+
+	// A zero Status is a 500
+	w := httptest.NewRecorder()
+	(&HTTPError{Message: "x"}).Write(ctx, w)
+	if body := decodeBody(t, w); w.Code != 500 || body["code"] != "internal_server_error" {
+		t.Errorf("expected 500 for a zero Status, got %d %v", w.Code, body)
+	}
+}
+
+func TestRouter_ProblemDetailsForBedrockErrors(t *testing.T) {
+	routes := []Route{{
+		Method:     "POST",
+		Path:       "/bookings",
+		Middleware: []Middleware{RequireAuth("secret")},
+		Handler: func(ctx context.Context, r *http.Request) Response {
+			return JSON(201, nil)
+		},
+	}}
+
+	var buf bytes.Buffer
+	router := testRouter(routes, Options{ProblemDetails: true}, &buf)
+
+	cases := []struct {
+		method, path string
+		status       int
+	}{
+		{"POST", "/bookings", 401},
+		{"GET", "/missing", 404},
+		{"DELETE", "/bookings", 405},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+
+		if w.Code != tc.status {
+			t.Errorf("%s %s: expected %d, got %d", tc.method, tc.path, tc.status, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/problem+json" {
+			t.Errorf("%s %s: Content-Type %q", tc.method, tc.path, got)
+		}
+		if body := decodeBody(t, w); body["status"] != float64(tc.status) || body["title"] != http.StatusText(tc.status) {
+			t.Errorf("%s %s: unexpected body %v", tc.method, tc.path, body)
+		}
+	}
+}
+
+func TestRouter_NotFoundIsJSONByDefault(t *testing.T) {
+	var buf bytes.Buffer
+	router := testRouter([]Route{helloRoute()}, Options{}, &buf)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/missing", nil))
+
+	if w.Code != 404 {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+	if body := decodeBody(t, w); body["error"] != "not found" || body["code"] != "not_found" {
+		t.Errorf("unexpected body: %v", body)
+	}
+}
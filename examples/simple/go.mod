@@ -8,5 +8,9 @@ require bedrock v0.0.0-00010101000000-000000000000
 
 require (
 	github.com/BurntSushi/toml v1.5.0 // indirect
+	github.com/golang-jwt/jwt/v5 v5.3.0 // indirect
 	github.com/gorilla/mux v1.8.1 // indirect
+	github.com/robfig/cron/v3 v3.0.1 // indirect
+	golang.org/x/crypto v0.46.0 // indirect
+	golang.org/x/sys v0.39.0 // indirect
 )
@@ -12,4 +12,6 @@ require (
 	golang.org/x/crypto v0.46.0
 )
 
-require github.com/robfig/cron/v3 v3.0.1 // indirect
+require github.com/robfig/cron/v3 v3.0.1
+
+require golang.org/x/sys v0.39.0 // indirect
@@ -0,0 +1,125 @@
+package bedrock
+
+import "strings"
+
+// RouteGroup builds routes that share a path prefix, middleware and,
+// optionally, a CORS config. Create one with Group and turn it into the
+// []Route returned by App.Routes with Routes.
+type RouteGroup struct {
+	prefix     string
+	middleware []Middleware
+	cors       *CORSConfig
+	entries    []groupEntry // routes and nested groups, in the order added
+}
+
+// groupEntry is either a route or a nested group.
+type groupEntry struct {
+	route Route
+	group *RouteGroup
+}
+
+// routeScope records the group a flattened route came from, so newRouter
+// can register it on a gorilla/mux subrouter for the group's prefix.
+type routeScope struct {
+	prefix string      // full prefix of the innermost group
+	cors   *CORSConfig // nearest CORS override, if any
+}
+
+// Group returns a route group whose routes are prefixed with prefix and
+// wrapped with middleware.
+//
+// Group middleware runs before route middleware, and outer groups before
+// nested ones, in the same left-to-right order as Chain.
+//
+// Example:
+//
+//	func (a *App) Routes() []bedrock.Route {
+//	    api := bedrock.Group("/api/v1", bedrock.RequireAuth(a.secret))
+//	    api.Add(
+//	        bedrock.Route{Method: "GET", Path: "/bookings", Handler: a.listBookings},
+//	        bedrock.Route{Method: "POST", Path: "/bookings", Handler: a.createBooking},
+//	    )
+//
+//	    admin := api.Group("/admin", a.requireAdmin)
+//	    admin.Add(bedrock.Route{Method: "DELETE", Path: "/bookings/{id}", Handler: a.deleteBooking})
+//
+//	    return api.Routes()
+//	    // GET    /api/v1/bookings             RequireAuth -> listBookings
+//	    // POST   /api/v1/bookings             RequireAuth -> createBooking
+//	    // DELETE /api/v1/admin/bookings/{id}  RequireAuth -> requireAdmin -> deleteBooking
+//	}
+func Group(prefix string, middleware ...Middleware) *RouteGroup {
+	return &RouteGroup{
+		prefix:     strings.TrimRight(prefix, "/"),
+		middleware: middleware,
+	}
+}
+
+// Group adds a nested group under g and returns it.
+// The nested group inherits g's prefix, middleware and CORS config.
+func (g *RouteGroup) Group(prefix string, middleware ...Middleware) *RouteGroup {
+	child := Group(prefix, middleware...)
+	g.entries = append(g.entries, groupEntry{group: child})
+	return child
+}
+
+// Add adds routes to g. Their paths are relative to the group prefix.
+func (g *RouteGroup) Add(routes ...Route) *RouteGroup {
+	for _, route := range routes {
+		g.entries = append(g.entries, groupEntry{route: route})
+	}
+	return g
+}
+
+// CORS overrides the app's CORS config for the routes of g and its nested
+// groups, including their OPTIONS preflight responses. A nested group can
+// override it again.
+//
+// Example:
+//
+//	public := bedrock.Group("/public").CORS(bedrock.CORSConfig{
+//	    AllowedOrigins: []string{"*"},
+//	    AllowedMethods: []string{"GET", "OPTIONS"},
+//	})
+func (g *RouteGroup) CORS(cfg CORSConfig) *RouteGroup {
+	g.cors = &cfg
+	return g
+}
+
+// Routes returns the routes of g and its nested groups, in the order they
+// were added, with full paths and the inherited middleware prepended to
+// each route's own.
+func (g *RouteGroup) Routes() []Route {
+	return g.flatten(routeScope{}, nil)
+}
+
+func (g *RouteGroup) flatten(parent routeScope, parentMiddleware []Middleware) []Route {
+	scope := &routeScope{prefix: parent.prefix + g.prefix, cors: parent.cors}
+	if g.cors != nil {
+		scope.cors = g.cors
+	}
+	middleware := append(append([]Middleware(nil), parentMiddleware...), g.middleware...)
+
+	var routes []Route
+	for _, entry := range g.entries {
+		if entry.group != nil {
+			routes = append(routes, entry.group.flatten(*scope, middleware)...)
+			continue
+		}
+
+		r := entry.route
+		r.Path = scope.prefix + r.Path
+		r.Middleware = append(append([]Middleware(nil), middleware...), r.Middleware...)
+		r.scope = scope
+		if entry.route.scope != nil {
+			// Routes of another group keep their own prefix and CORS override
+			nested := &routeScope{prefix: scope.prefix + entry.route.scope.prefix, cors: entry.route.scope.cors}
+			if nested.cors == nil {
+				nested.cors = scope.cors
+			}
+			r.scope = nested
+		}
+		routes = append(routes, r)
+	}
+	return routes
+}
@@ -0,0 +1,157 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// tagMiddleware appends name to the X-Chain response header when it runs.
+func tagMiddleware(name string, order *[]string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, r *http.Request) Response {
+			*order = append(*order, name)
+			return next(ctx, r)
+		}
+	}
+}
+
+func varsHandler(ctx context.Context, r *http.Request) Response {
+	return JSON(200, mux.Vars(r))
+}
+
+func TestGroup_Routes(t *testing.T) {
+	var order []string
+	api := Group("/api/", tagMiddleware("api", &order))
+	api.Add(Route{Method: "GET", Path: "/bookings", Handler: varsHandler})
+	admin := api.Group("/admin", tagMiddleware("admin", &order))
+	admin.Add(Route{
+		Method:     "DELETE",
+		Path:       "/bookings/{id}",
+		Handler:    varsHandler,
+		Middleware: []Middleware{tagMiddleware("route", &order)},
+	})
+	api.Add(Route{Method: "GET", Path: "", Handler: varsHandler})
+
+	routes := api.Routes()
+
+	var paths []string
+	for _, r := range routes {
+		paths = append(paths, r.Method+" "+r.Path)
+	}
+	want := []string{"GET /api/bookings", "DELETE /api/admin/bookings/{id}", "GET /api"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("routes: got %v, want %v", paths, want)
+	}
+
+	Chain(routes[1].Handler, routes[1].Middleware...)(context.Background(), httptest.NewRequest("DELETE", "/", nil))
+	if !reflect.DeepEqual(order, []string{"api", "admin", "route"}) {
+		t.Errorf("middleware order: got %v", order)
+	}
+}
+
+func TestGroup_DoesNotShareMiddlewareSlices(t *testing.T) {
+	var order []string
+	api := Group("/api", tagMiddleware("api", &order))
+	a := api.Group("/a", tagMiddleware("a", &order))
+	b := api.Group("/b", tagMiddleware("b", &order))
+	a.Add(Route{Method: "GET", Path: "/x", Handler: varsHandler})
+	b.Add(Route{Method: "GET", Path: "/x", Handler: varsHandler})
+
+	routes := api.Routes()
+	if len(routes[0].Middleware) != 2 || len(routes[1].Middleware) != 2 {
+		t.Fatalf("unexpected middleware: %d, %d", len(routes[0].Middleware), len(routes[1].Middleware))
+	}
+	Chain(routes[0].Handler, routes[0].Middleware...)(context.Background(), httptest.NewRequest("GET", "/", nil))
+	if !reflect.DeepEqual(order, []string{"api", "a"}) {
+		t.Errorf("sibling group middleware leaked: %v", order)
+	}
+}
+
+func TestGroup_MatchesThroughSubrouters(t *testing.T) {
+	tenant := Group("/tenants/{tenant}")
+	tenant.Add(
+		Route{Method: "GET", Path: "/rooms/{room}", Handler: varsHandler},
+		Route{Method: "GET", Path: "/files/", Handler: varsHandler, IsPrefix: true},
+	)
+	routes := append(tenant.Routes(), helloRoute())
+
+	var buf bytes.Buffer
+	router := testRouter(routes, Options{}, &buf)
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{"GET", "/tenants/acme/rooms/7", 200},
+		{"GET", "/tenants/acme/files/a/b.txt", 200},
+		{"GET", "/hello", 200},
+		{"GET", "/tenants/acme/nothing", 404},
+		{"POST", "/tenants/acme/rooms/7", 405},
+		{"OPTIONS", "/tenants/acme/rooms/7", 200},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s %s: got %d, want %d", tt.method, tt.path, w.Code, tt.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/tenants/acme/rooms/7", nil))
+	body := decodeBody(t, w)
+	if body["tenant"] != "acme" || body["room"] != "7" {
+		t.Errorf("path variables from prefix and route expected, got %v", body)
+	}
+}
+
+func TestGroup_CORSOverride(t *testing.T) {
+	partner := Group("/partner").CORS(CORSConfig{
+		AllowedOrigins: []string{"https://partner.example"},
+		AllowedMethods: []string{"GET"},
+	})
+	partner.Add(Route{Method: "GET", Path: "/feed", Handler: varsHandler})
+	inherits := partner.Group("/v2")
+	inherits.Add(Route{Method: "GET", Path: "/feed", Handler: varsHandler})
+	routes := append(partner.Routes(), helloRoute())
+
+	cors := CORSConfig{AllowedOrigins: []string{"https://app.example"}, AllowedMethods: []string{"GET", "POST"}}
+	var buf bytes.Buffer
+	router := testRouter(routes, Options{CORS: &cors}, &buf)
+
+	request := func(method, path, origin string) http.Header {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Header()
+	}
+
+	for _, path := range []string{"/partner/feed", "/partner/v2/feed"} {
+		if got := request("GET", path, "https://partner.example").Get("Access-Control-Allow-Origin"); got != "https://partner.example" {
+			t.Errorf("%s: group origin not allowed, got %q", path, got)
+		}
+		h := request("OPTIONS", path, "https://app.example")
+		if got := h.Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("%s: app-wide origin should be replaced by the override, got %q", path, got)
+		}
+		if got := h.Get("Access-Control-Allow-Methods"); got != "GET" {
+			t.Errorf("%s: preflight methods: got %q", path, got)
+		}
+	}
+
+	h := request("GET", "/hello", "https://app.example")
+	if got := h.Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+		t.Errorf("routes outside the group keep the app-wide config, got %q", got)
+	}
+	if got := h.Get("Access-Control-Allow-Methods"); !strings.Contains(got, "POST") {
+		t.Errorf("unexpected methods outside the group: %q", got)
+	}
+}
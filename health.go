@@ -1,17 +1,52 @@
 package bedrock
 
 import (
+	"context"
 	"encoding/json"
-	"log"
 	"net/http"
 	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
 )
 
+// defaultHealthCheckTimeout bounds a HealthCheck when its Timeout is unset.
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// HealthCheck is a named dependency check run on every /ready request,
+// such as a database or cache ping.
+type HealthCheck struct {
+	// Name identifies the check in the /ready response, e.g. "postgres".
+	Name string
+	// Check returns nil if the dependency is healthy. ctx is cancelled after Timeout.
+	Check func(ctx context.Context) error
+	// Timeout bounds each run of Check. Defaults to 2s if zero.
+	Timeout time.Duration
+	// Critical checks make /ready return 503 when they fail.
+	// Failing non-critical checks only mark the app as degraded.
+	Critical bool
+}
+
+// HealthChecker is an optional interface apps can implement to register dependency checks.
+// HealthChecks is called once, after OnStart succeeds.
+type HealthChecker interface {
+	HealthChecks() []HealthCheck
+}
+
+// CheckResult is the outcome of a single HealthCheck as reported by /ready.
+type CheckResult struct {
+	Status    string  `json:"status"` // "ok" or "failing"
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
 // HealthStatus tracks application health
 type HealthStatus struct {
 	mu      sync.RWMutex
 	healthy bool
 	ready   bool
+	checks  []HealthCheck
 }
 
 func newHealthStatus() *HealthStatus {
@@ -45,6 +80,82 @@ func (h *HealthStatus) IsReady() bool {
 	return h.ready
 }
 
+// SetChecks replaces the dependency checks run by /ready.
+func (h *HealthStatus) SetChecks(checks []HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = checks
+}
+
+// RunChecks runs all dependency checks concurrently and returns their results
+// keyed by name, along with whether any critical or non-critical check failed.
+func (h *HealthStatus) RunChecks(ctx context.Context) (results map[string]CheckResult, criticalFailed, degraded bool) {
+	h.mu.RLock()
+	checks := h.checks
+	h.mu.RUnlock()
+
+	results = make(map[string]CheckResult, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := runCheck(ctx, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[c.Name] = result
+			if result.Status != "ok" {
+				if c.Critical {
+					criticalFailed = true
+				} else {
+					degraded = true
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results, criticalFailed, degraded
+}
+
+// runCheck runs a single check under its timeout.
+// A check that ignores ctx is abandoned once the timeout expires.
+func runCheck(ctx context.Context, c HealthCheck) CheckResult {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{
+		Status:    "ok",
+		Critical:  c.Critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "failing"
+		result.Error = err.Error()
+	}
+	return result
+}
+
 // healthCheckHandler returns an http.HandlerFunc for the /health endpoint
 func healthCheckHandler(status *HealthStatus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -58,16 +169,42 @@ func healthCheckHandler(status *HealthStatus) http.HandlerFunc {
 	}
 }
 
-// readyCheckHandler returns an http.HandlerFunc for the /ready endpoint
+// readyCheckHandler returns an http.HandlerFunc for the /ready endpoint.
+// When dependency checks are registered, the response includes a per-check
+// breakdown and the status is "degraded" if only non-critical checks fail.
 func readyCheckHandler(status *HealthStatus) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if status.IsReady() {
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
-		} else {
+		if !status.IsReady() {
 			w.WriteHeader(http.StatusServiceUnavailable)
 			json.NewEncoder(w).Encode(map[string]string{"status": "not ready"})
+			return
+		}
+
+		status.mu.RLock()
+		hasChecks := len(status.checks) > 0
+		status.mu.RUnlock()
+		if !hasChecks {
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
+			return
+		}
+
+		results, criticalFailed, degraded := status.RunChecks(r.Context())
+
+		code, state := http.StatusOK, "ready"
+		switch {
+		case criticalFailed:
+			code, state = http.StatusServiceUnavailable, "not ready"
+		case degraded:
+			state = "degraded"
 		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": state,
+			"checks": results,
+		})
 	}
 }
 
@@ -76,25 +213,24 @@ func liveCheckHandler(status *HealthStatus) http.HandlerFunc {
 	return healthCheckHandler(status)
 }
 
-func startHealthServer(port string, status *HealthStatus) *http.Server {
-	mux := http.NewServeMux()
+// registerHealthEndpoints adds /health, /ready and /live to router.
+func registerHealthEndpoints(router *mux.Router, status *HealthStatus) {
+	router.HandleFunc("/health", healthCheckHandler(status))
+	router.HandleFunc("/ready", readyCheckHandler(status))
+	router.HandleFunc("/live", liveCheckHandler(status))
+}
 
-	// Register health endpoints
-	mux.HandleFunc("/health", healthCheckHandler(status))
-	mux.HandleFunc("/ready", readyCheckHandler(status))
-	mux.HandleFunc("/live", liveCheckHandler(status))
+// newHealthServer returns an unstarted server exposing the health endpoints.
+// If metrics is non-nil, /metrics is served on the same port.
+func newHealthServer(port string, status *HealthStatus, metrics *Registry) *http.Server {
+	router := mux.NewRouter()
+	registerHealthEndpoints(router, status)
+	if metrics != nil {
+		registerMetricsEndpoint(router, metrics)
+	}
 
-	server := &http.Server{
+	return &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: router,
 	}
-
-	go func() {
-		log.Printf("Starting health server on :%s", port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("Health server error: %v", err)
-		}
-	}()
-
-	return server
 }
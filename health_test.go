@@ -0,0 +1,137 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type readyBody struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+func getReady(t *testing.T, status *HealthStatus) (int, readyBody) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	readyCheckHandler(status)(w, httptest.NewRequest("GET", "/ready", nil))
+
+	var body readyBody
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode /ready body: %v", err)
+	}
+	return w.Code, body
+}
+
+func readyStatus(checks ...HealthCheck) *HealthStatus {
+	status := newHealthStatus()
+	status.SetHealthy(true)
+	status.SetReady(true)
+	status.SetChecks(checks)
+	return status
+}
+
+func passing(ctx context.Context) error { return nil }
+
+func failing(ctx context.Context) error { return errors.New("connection refused") }
+
+func TestReady_NoChecks(t *testing.T) {
+	code, body := getReady(t, readyStatus())
+
+	if code != http.StatusOK {
+		t.Errorf("expected 200, got %d", code)
+	}
+	if body.Status != "ready" || body.Checks != nil {
+		t.Errorf("unexpected body: %+v", body)
+	}
+}
+
+func TestReady_NotReadySkipsChecks(t *testing.T) {
+	called := false
+	status := readyStatus(HealthCheck{Name: "db", Critical: true, Check: func(ctx context.Context) error {
+		called = true
+		return nil
+	}})
+	status.SetReady(false)
+
+	code, body := getReady(t, status)
+
+	if code != http.StatusServiceUnavailable || body.Status != "not ready" {
+		t.Errorf("expected 503 not ready, got %d %q", code, body.Status)
+	}
+	if called {
+		t.Error("checks should not run before the app is ready")
+	}
+}
+
+func TestReady_AllChecksPass(t *testing.T) {
+	code, body := getReady(t, readyStatus(
+		HealthCheck{Name: "db", Check: passing, Critical: true},
+		HealthCheck{Name: "cache", Check: passing},
+	))
+
+	if code != http.StatusOK || body.Status != "ready" {
+		t.Errorf("expected 200 ready, got %d %q", code, body.Status)
+	}
+	if len(body.Checks) != 2 {
+		t.Fatalf("expected 2 check results, got %d", len(body.Checks))
+	}
+	if db := body.Checks["db"]; db.Status != "ok" || !db.Critical || db.Error != "" {
+		t.Errorf("unexpected db result: %+v", db)
+	}
+}
+
+func TestReady_NonCriticalFailureIsDegraded(t *testing.T) {
+	code, body := getReady(t, readyStatus(
+		HealthCheck{Name: "db", Check: passing, Critical: true},
+		HealthCheck{Name: "cache", Check: failing},
+	))
+
+	if code != http.StatusOK || body.Status != "degraded" {
+		t.Errorf("expected 200 degraded, got %d %q", code, body.Status)
+	}
+	if cache := body.Checks["cache"]; cache.Status != "failing" || cache.Error != "connection refused" {
+		t.Errorf("unexpected cache result: %+v", cache)
+	}
+}
+
+func TestReady_CriticalFailureIsNotReady(t *testing.T) {
+	code, body := getReady(t, readyStatus(
+		HealthCheck{Name: "db", Check: failing, Critical: true},
+		HealthCheck{Name: "cache", Check: failing},
+	))
+
+	if code != http.StatusServiceUnavailable || body.Status != "not ready" {
+		t.Errorf("expected 503 not ready, got %d %q", code, body.Status)
+	}
+}
+
+func TestReady_CheckTimeout(t *testing.T) {
+	slow := func(ctx context.Context) error {
+		select {
+		case <-time.After(time.Second):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	start := time.Now()
+	code, body := getReady(t, readyStatus(
+		HealthCheck{Name: "db", Check: slow, Timeout: 20 * time.Millisecond, Critical: true},
+	))
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("check was not bounded by its timeout: took %v", elapsed)
+	}
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", code)
+	}
+	if db := body.Checks["db"]; db.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("expected deadline exceeded error, got %+v", db)
+	}
+}
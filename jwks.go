@@ -0,0 +1,481 @@
+package bedrock
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWKSPath is the conventional path for serving a JSON Web Key Set.
+const JWKSPath = "/.well-known/jwks.json"
+
+// KeyProvider resolves the public key that verifies a token, by its kid
+// and alg headers. KeySet and RemoteKeySet implement it.
+type KeyProvider interface {
+	VerificationKey(ctx context.Context, kid, alg string) (crypto.PublicKey, error)
+}
+
+// ErrUnknownKey is returned by a KeyProvider that has no key for a token.
+var ErrUnknownKey = errors.New("bedrock: unknown signing key")
+
+// jwk is a single key of a KeySet.
+type jwk struct {
+	id     string
+	alg    string           // "RS256", "ES256", "ES384", "ES512" or "EdDSA"
+	signer crypto.Signer    // nil for verification-only keys
+	public crypto.PublicKey // *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+}
+
+// KeySet holds asymmetric keys for signing and verifying JWTs. Each key has
+// an ID that is written to the kid header of the tokens it signs.
+//
+// RSA keys sign with RS256, ECDSA keys with ES256, ES384 or ES512 depending
+// on the curve, and Ed25519 keys with EdDSA.
+//
+// To rotate keys, add the new signing key: it becomes the active one, and
+// the previous key keeps verifying tokens it already signed. Remove the old
+// key once those tokens have expired.
+//
+// A KeySet is safe for concurrent use.
+//
+// Example:
+//
+//	keys := bedrock.NewKeySet()
+//	if err := keys.AddSigningKey("2024-05", privateKey); err != nil {
+//	    return err
+//	}
+//	token, err := keys.GenerateJWT("user123", time.Hour)
+//
+//	routes := []bedrock.Route{
+//	    {Method: "GET", Path: bedrock.JWKSPath, Handler: keys.JWKSHandler()},
+//	    {Method: "GET", Path: "/me", Handler: a.me, Middleware: []bedrock.Middleware{bedrock.RequireAuthWithKeys(keys)}},
+//	}
+type KeySet struct {
+	mu      sync.RWMutex
+	keys    map[string]*jwk
+	signing string // kid of the active signing key
+}
+
+// NewKeySet returns an empty KeySet.
+func NewKeySet() *KeySet {
+	return &KeySet{keys: make(map[string]*jwk)}
+}
+
+// AddSigningKey adds a private key and makes it the active signing key.
+// key must be an *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey.
+// Previously added keys remain available for verification.
+func (ks *KeySet) AddSigningKey(kid string, key crypto.Signer) error {
+	if kid == "" {
+		return errors.New("bedrock: key ID must not be empty")
+	}
+	k, err := newJWK(kid, key.Public())
+	if err != nil {
+		return err
+	}
+	k.signer = key
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[kid] = k
+	ks.signing = kid
+	return nil
+}
+
+// AddVerificationKey adds a public key that verifies tokens but never signs.
+// key must be an *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+func (ks *KeySet) AddVerificationKey(kid string, key crypto.PublicKey) error {
+	if kid == "" {
+		return errors.New("bedrock: key ID must not be empty")
+	}
+	k, err := newJWK(kid, key)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[kid] = k
+	return nil
+}
+
+// RemoveKey removes a key. Tokens it signed no longer verify. If it was the
+// active signing key, the KeySet cannot sign until another is added.
+func (ks *KeySet) RemoveKey(kid string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	delete(ks.keys, kid)
+	if ks.signing == kid {
+		ks.signing = ""
+	}
+}
+
+// VerificationKey implements KeyProvider. A token without a kid is accepted
+// only if the set holds exactly one key.
+func (ks *KeySet) VerificationKey(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return lookupKey(ks.keys, kid, alg)
+}
+
+// lookupKey finds the key for kid and checks that it uses alg.
+func lookupKey(keys map[string]*jwk, kid, alg string) (crypto.PublicKey, error) {
+	k, ok := keys[kid]
+	if kid == "" && len(keys) == 1 {
+		for _, only := range keys {
+			k, ok = only, true
+		}
+	}
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if k.alg != alg {
+		return nil, fmt.Errorf("bedrock: key %q uses %s, token uses %s", k.id, k.alg, alg)
+	}
+	return k.public, nil
+}
+
+// Sign signs claims with the active signing key, setting the kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	ks.mu.RLock()
+	k, ok := ks.keys[ks.signing]
+	ks.mu.RUnlock()
+	if !ok {
+		return "", errors.New("bedrock: KeySet has no signing key")
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(k.alg), claims)
+	token.Header["kid"] = k.id
+	return token.SignedString(k.signer)
+}
+
+// GenerateJWT is like the package-level GenerateJWT, but signs with the
+// active signing key of the set.
+func (ks *KeySet) GenerateJWT(userID string, expiration time.Duration) (string, error) {
+	return ks.Sign(NewRegisteredClaims(userID, expiration))
+}
+
+// JWKSHandler serves the public keys of the set as a JSON Web Key Set
+// (RFC 7517), conventionally at JWKSPath. Private keys are never included.
+func (ks *KeySet) JWKSHandler() Handler {
+	return func(ctx context.Context, r *http.Request) Response {
+		ks.mu.RLock()
+		set := jwkSet{Keys: make([]jwkJSON, 0, len(ks.keys))}
+		for _, k := range ks.keys {
+			set.Keys = append(set.Keys, k.toJSON())
+		}
+		ks.mu.RUnlock()
+
+		sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+		return JSONWithHeaders(http.StatusOK, set, http.Header{"Cache-Control": {"public, max-age=300"}})
+	}
+}
+
+// DefaultJWKSCacheTTL is how long a RemoteKeySet caches keys when no TTL is given.
+const DefaultJWKSCacheTTL = 10 * time.Minute
+
+// jwksMinRefresh limits how often an unknown kid can trigger a refetch.
+const jwksMinRefresh = 30 * time.Second
+
+// RemoteKeySet is a KeyProvider backed by a JWKS URL, typically another
+// service's JWKSPath. Keys are fetched on first use and cached for the TTL;
+// expired keys keep being used while a refetch runs in the background.
+// A token with an unknown kid triggers an early refetch (at most every 30s),
+// so keys rotated by the issuer are picked up without waiting for the TTL.
+// If a refetch fails, the cached keys keep being used. Until keys have been
+// fetched once, every verification retries.
+//
+// Fetches are shared by concurrent verifications and are not cancelled
+// with the request that started them.
+//
+// Example:
+//
+//	keys := bedrock.NewRemoteKeySet("https://auth.internal/.well-known/jwks.json", 0)
+//	auth := bedrock.RequireAuthWithKeys(keys)
+type RemoteKeySet struct {
+	url    string
+	ttl    time.Duration
+	Client *http.Client // optional; defaults to a client with a 10s timeout
+
+	mu          sync.Mutex
+	keys        map[string]*jwk
+	nextFetch   time.Time  // when the cached keys expire
+	lastAttempt time.Time  // last fetch, successful or not
+	inflight    *jwksFetch // the fetch in progress, if any
+}
+
+// jwksFetch is a fetch shared by everyone waiting for it. err is set
+// before done is closed.
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewRemoteKeySet returns a RemoteKeySet for url. A cacheTTL of 0 uses
+// DefaultJWKSCacheTTL.
+func NewRemoteKeySet(url string, cacheTTL time.Duration) *RemoteKeySet {
+	if cacheTTL == 0 {
+		cacheTTL = DefaultJWKSCacheTTL
+	}
+	return &RemoteKeySet{url: url, ttl: cacheTTL}
+}
+
+// VerificationKey implements KeyProvider.
+func (rs *RemoteKeySet) VerificationKey(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	rs.mu.Lock()
+	keys := rs.keys
+	if keys == nil || time.Now().After(rs.nextFetch) {
+		f := rs.startFetch(ctx)
+		rs.mu.Unlock()
+		if keys == nil {
+			// Nothing to verify with until the first fetch succeeds
+			if err := f.wait(ctx); err != nil {
+				return nil, err
+			}
+			keys = rs.cachedKeys()
+		}
+	} else {
+		rs.mu.Unlock()
+	}
+
+	key, err := lookupKey(keys, kid, alg)
+	if errors.Is(err, ErrUnknownKey) {
+		// The issuer may have rotated to a key we have not seen yet
+		rs.mu.Lock()
+		var f *jwksFetch
+		if rs.inflight != nil || time.Since(rs.lastAttempt) > jwksMinRefresh {
+			f = rs.startFetch(ctx)
+		}
+		rs.mu.Unlock()
+		if f != nil && f.wait(ctx) == nil {
+			key, err = lookupKey(rs.cachedKeys(), kid, alg)
+		}
+	}
+	return key, err
+}
+
+// cachedKeys returns the keys from the last successful fetch.
+func (rs *RemoteKeySet) cachedKeys() map[string]*jwk {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.keys
+}
+
+// startFetch returns the fetch in progress, or starts one. The fetch
+// outlives ctx's cancellation, so a client that goes away does not fail it
+// for everyone else; the HTTP client's timeout bounds it. rs.mu must be held.
+func (rs *RemoteKeySet) startFetch(ctx context.Context) *jwksFetch {
+	if rs.inflight != nil {
+		return rs.inflight
+	}
+	f := &jwksFetch{done: make(chan struct{})}
+	rs.inflight = f
+	rs.lastAttempt = time.Now()
+	go rs.refresh(context.WithoutCancel(ctx), f)
+	return f
+}
+
+// refresh runs f. After a failure the cached keys are kept and the next
+// attempt waits jwksMinRefresh, unless no keys were ever fetched.
+func (rs *RemoteKeySet) refresh(ctx context.Context, f *jwksFetch) {
+	keys, err := rs.fetch(ctx)
+
+	rs.mu.Lock()
+	now := time.Now()
+	if err != nil {
+		rs.nextFetch = now.Add(jwksMinRefresh)
+	} else {
+		rs.keys, rs.nextFetch = keys, now.Add(rs.ttl)
+	}
+	rs.inflight = nil
+	rs.mu.Unlock()
+
+	f.err = err
+	close(f.done)
+}
+
+// wait blocks until f finishes or ctx is done.
+func (f *jwksFetch) wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fetch downloads and parses the key set.
+func (rs *RemoteKeySet) fetch(ctx context.Context) (map[string]*jwk, error) {
+	client := rs.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rs.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock: fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bedrock: fetching JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	var set jwkSet
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, 1<<20)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("bedrock: decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]*jwk, len(set.Keys))
+	for _, raw := range set.Keys {
+		if k, err := raw.parse(); err == nil {
+			keys[k.id] = k
+		}
+		// Keys of unsupported types or uses are skipped, as RFC 7517 requires
+	}
+	return keys, nil
+}
+
+// newJWK validates a public key and picks its signing algorithm.
+func newJWK(kid string, public crypto.PublicKey) (*jwk, error) {
+	k := &jwk{id: kid, public: public}
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 2048 {
+			return nil, errors.New("bedrock: RSA keys must be at least 2048 bits")
+		}
+		k.alg = "RS256"
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			k.alg = "ES256"
+		case elliptic.P384():
+			k.alg = "ES384"
+		case elliptic.P521():
+			k.alg = "ES512"
+		default:
+			return nil, errors.New("bedrock: unsupported ECDSA curve")
+		}
+	case ed25519.PublicKey:
+		k.alg = "EdDSA"
+	default:
+		return nil, fmt.Errorf("bedrock: unsupported key type %T", public)
+	}
+	return k, nil
+}
+
+// jwkSet is the JSON form of a JSON Web Key Set.
+type jwkSet struct {
+	Keys []jwkJSON `json:"keys"`
+}
+
+// jwkJSON is the JSON form of a public JSON Web Key (RFC 7517, 7518, 8037).
+type jwkJSON struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // EC or OKP curve
+	X   string `json:"x,omitempty"`   // EC x coordinate or Ed25519 public key
+	Y   string `json:"y,omitempty"`   // EC y coordinate
+}
+
+var b64url = base64.RawURLEncoding
+
+func (k *jwk) toJSON() jwkJSON {
+	j := jwkJSON{Kid: k.id, Use: "sig", Alg: k.alg}
+	switch key := k.public.(type) {
+	case *rsa.PublicKey:
+		j.Kty = "RSA"
+		j.N = b64url.EncodeToString(key.N.Bytes())
+		j.E = b64url.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		j.Kty, j.Crv = "EC", key.Curve.Params().Name
+		point, _ := key.Bytes() // 0x04 || x || y; validated by newJWK
+		size := (len(point) - 1) / 2
+		j.X = b64url.EncodeToString(point[1 : 1+size])
+		j.Y = b64url.EncodeToString(point[1+size:])
+	case ed25519.PublicKey:
+		j.Kty, j.Crv = "OKP", "Ed25519"
+		j.X = b64url.EncodeToString(key)
+	}
+	return j
+}
+
+// parse converts a JSON Web Key into a verification key.
+func (j jwkJSON) parse() (*jwk, error) {
+	if j.Use != "" && j.Use != "sig" {
+		return nil, errors.New("not a signing key")
+	}
+
+	var public crypto.PublicKey
+	switch j.Kty {
+	case "RSA":
+		n, err1 := b64url.DecodeString(j.N)
+		e, err2 := b64url.DecodeString(j.E)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[j.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err1 := b64url.DecodeString(j.X)
+		y, err2 := b64url.DecodeString(j.Y)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, err
+		}
+		key, err := ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, err
+		}
+		public = key
+
+	case "OKP":
+		x, err := b64url.DecodeString(j.X)
+		if err != nil || j.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		public = ed25519.PublicKey(x)
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+	}
+
+	k, err := newJWK(j.Kid, public)
+	if err != nil {
+		return nil, err
+	}
+	if j.Alg != "" && j.Alg != k.alg {
+		return nil, fmt.Errorf("unsupported algorithm %q", j.Alg)
+	}
+	return k, nil
+}
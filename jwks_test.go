@@ -0,0 +1,324 @@
+package bedrock
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testSigners(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]crypto.Signer{"RS256": rsaKey, "ES256": ecKey, "EdDSA": edKey}
+}
+
+func TestKeySet_SignAndVerify(t *testing.T) {
+	for alg, signer := range testSigners(t) {
+		keys := NewKeySet()
+		if err := keys.AddSigningKey("k1", signer); err != nil {
+			t.Fatalf("%s: AddSigningKey failed: %v", alg, err)
+		}
+		token, err := keys.GenerateJWT("user123", time.Hour)
+		if err != nil {
+			t.Fatalf("%s: GenerateJWT failed: %v", alg, err)
+		}
+
+		parsed, _, _ := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+		if parsed.Header["alg"] != alg || parsed.Header["kid"] != "k1" {
+			t.Errorf("%s: unexpected header %v", alg, parsed.Header)
+		}
+
+		userID, err := ValidateJWTWithKeys(context.Background(), token, keys)
+		if err != nil || userID != "user123" {
+			t.Errorf("%s: got %q, %v", alg, userID, err)
+		}
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	signers := testSigners(t)
+	keys := NewKeySet()
+	keys.AddSigningKey("old", signers["RS256"])
+	oldToken, _ := keys.GenerateJWT("user123", time.Hour)
+
+	keys.AddSigningKey("new", signers["ES256"])
+	newToken, _ := keys.GenerateJWT("user123", time.Hour)
+
+	ctx := context.Background()
+	for _, token := range []string{oldToken, newToken} {
+		if _, err := ValidateJWTWithKeys(ctx, token, keys); err != nil {
+			t.Errorf("expected both keys to verify during rotation: %v", err)
+		}
+	}
+
+	keys.RemoveKey("old")
+	if _, err := ValidateJWTWithKeys(ctx, oldToken, keys); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey after removal, got %v", err)
+	}
+	if _, err := ValidateJWTWithKeys(ctx, newToken, keys); err != nil {
+		t.Errorf("new key should still verify: %v", err)
+	}
+}
+
+func TestKeySet_RejectsMismatchedTokens(t *testing.T) {
+	signers := testSigners(t)
+	keys := NewKeySet()
+	keys.AddSigningKey("k1", signers["ES256"])
+	ctx := context.Background()
+
+	// An HMAC token is refused, whatever its kid
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, NewRegisteredClaims("user123", time.Hour))
+	hmacToken.Header["kid"] = "k1"
+	signed, _ := hmacToken.SignedString([]byte("secret"))
+	if _, err := ValidateJWTWithKeys(ctx, signed, keys); err == nil {
+		t.Error("expected HS256 token to be rejected")
+	}
+
+	// So is a token whose alg does not match the key named by its kid
+	other := NewKeySet()
+	other.AddSigningKey("k1", signers["EdDSA"])
+	signed, _ = other.GenerateJWT("user123", time.Hour)
+	if _, err := ValidateJWTWithKeys(ctx, signed, keys); err == nil {
+		t.Error("expected token with mismatched alg to be rejected")
+	}
+
+	if err := keys.AddSigningKey("", signers["RS256"]); err == nil {
+		t.Error("expected empty key ID to be rejected")
+	}
+	small, _ := rsa.GenerateKey(rand.Reader, 1024)
+	if err := keys.AddSigningKey("small", small); err == nil {
+		t.Error("expected 1024-bit RSA key to be rejected")
+	}
+}
+
+func TestJWKSHandler(t *testing.T) {
+	signers := testSigners(t)
+	keys := NewKeySet()
+	for _, alg := range []string{"RS256", "ES256", "EdDSA"} {
+		keys.AddSigningKey(alg, signers[alg])
+	}
+
+	w := httptest.NewRecorder()
+	keys.JWKSHandler()(context.Background(), httptest.NewRequest("GET", JWKSPath, nil)).Write(context.Background(), w)
+
+	if w.Header().Get("Cache-Control") == "" {
+		t.Error("expected Cache-Control header")
+	}
+	var set jwkSet
+	if err := json.NewDecoder(w.Body).Decode(&set); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 3 {
+		t.Fatalf("expected 3 keys, got %+v", set.Keys)
+	}
+
+	want := map[string]string{"EdDSA": "OKP", "ES256": "EC", "RS256": "RSA"}
+	for _, key := range set.Keys {
+		if key.Kty != want[key.Kid] || key.Alg != key.Kid || key.Use != "sig" {
+			t.Errorf("unexpected key %+v", key)
+		}
+		parsed, err := key.parse()
+		if err != nil {
+			t.Errorf("%s: published key does not parse: %v", key.Kid, err)
+			continue
+		}
+		if !parsed.public.(interface{ Equal(crypto.PublicKey) bool }).Equal(signers[key.Kid].Public()) {
+			t.Errorf("%s: published key differs from the signing key", key.Kid)
+		}
+	}
+}
+
+// jwksServer serves keys as a JWKS and counts the requests it receives.
+func jwksServer(t *testing.T, keys *KeySet) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	handler := keys.JWKSHandler()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		handler(r.Context(), r).Write(r.Context(), w)
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	signers := testSigners(t)
+	issuer := NewKeySet()
+	issuer.AddSigningKey("k1", signers["RS256"])
+	server, hits := jwksServer(t, issuer)
+
+	remote := NewRemoteKeySet(server.URL, time.Hour)
+	ctx := context.Background()
+
+	token, _ := issuer.GenerateJWT("user123", time.Hour)
+	for range 3 {
+		if userID, err := ValidateJWTWithKeys(ctx, token, remote); err != nil || userID != "user123" {
+			t.Fatalf("got %q, %v", userID, err)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected keys to be cached, got %d fetches", n)
+	}
+
+	// A token signed with a rotated-in key triggers a refetch once the
+	// minimum refresh interval has passed
+	issuer.AddSigningKey("k2", signers["EdDSA"])
+	rotated, _ := issuer.GenerateJWT("user456", time.Hour)
+
+	remote.mu.Lock()
+	remote.lastAttempt = time.Now().Add(-time.Minute)
+	remote.mu.Unlock()
+
+	if userID, err := ValidateJWTWithKeys(ctx, rotated, remote); err != nil || userID != "user456" {
+		t.Fatalf("rotated key: got %q, %v", userID, err)
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("expected one refetch, got %d fetches", n)
+	}
+
+	// Unknown kids do not refetch again within the minimum interval
+	stranger := NewKeySet()
+	stranger.AddSigningKey("k3", signers["ES256"])
+	forged, _ := stranger.GenerateJWT("user123", time.Hour)
+	for range 3 {
+		if _, err := ValidateJWTWithKeys(ctx, forged, remote); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("expected ErrUnknownKey, got %v", err)
+		}
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("expected refetches to be rate limited, got %d fetches", n)
+	}
+}
+
+func TestRemoteKeySet_KeepsStaleKeysOnError(t *testing.T) {
+	signers := testSigners(t)
+	issuer := NewKeySet()
+	issuer.AddSigningKey("k1", signers["ES256"])
+	server, _ := jwksServer(t, issuer)
+
+	remote := NewRemoteKeySet(server.URL, time.Hour)
+	token, _ := issuer.GenerateJWT("user123", time.Hour)
+	ctx := context.Background()
+	if _, err := ValidateJWTWithKeys(ctx, token, remote); err != nil {
+		t.Fatal(err)
+	}
+
+	// Expire the cache and take the server down
+	server.Close()
+	remote.mu.Lock()
+	remote.nextFetch = time.Time{}
+	remote.mu.Unlock()
+
+	if _, err := ValidateJWTWithKeys(ctx, token, remote); err != nil {
+		t.Errorf("expected cached keys to be used when refresh fails: %v", err)
+	}
+
+	unreachable := NewRemoteKeySet(server.URL, 0)
+	if _, err := ValidateJWTWithKeys(ctx, token, unreachable); err == nil {
+		t.Error("expected error when keys were never fetched")
+	}
+}
+
+func TestRemoteKeySet_FirstFetch(t *testing.T) {
+	signers := testSigners(t)
+	issuer := NewKeySet()
+	issuer.AddSigningKey("k1", signers["EdDSA"])
+	handler := issuer.JWKSHandler()
+	var down atomic.Bool
+	release := make(chan struct{})
+	var slow atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow.Load() {
+			<-release
+		}
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler(r.Context(), r).Write(r.Context(), w)
+	}))
+	t.Cleanup(server.Close)
+	token, _ := issuer.GenerateJWT("user123", time.Hour)
+
+	// A request that is cancelled mid-fetch does not fail later ones
+	remote := NewRemoteKeySet(server.URL, time.Hour)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	ValidateJWTWithKeys(cancelled, token, remote)
+	if _, err := ValidateJWTWithKeys(context.Background(), token, remote); err != nil {
+		t.Errorf("expected the key after a cancelled fetch, got %v", err)
+	}
+
+	// Without any keys, a failed fetch is retried right away
+	remote = NewRemoteKeySet(server.URL, time.Hour)
+	down.Store(true)
+	if _, err := ValidateJWTWithKeys(context.Background(), token, remote); err == nil {
+		t.Fatal("expected error while the server is down")
+	}
+	down.Store(false)
+	if _, err := ValidateJWTWithKeys(context.Background(), token, remote); err != nil {
+		t.Errorf("expected the key once the server is back, got %v", err)
+	}
+
+	// Expired keys are refreshed in the background, not while verifying
+	slow.Store(true)
+	defer close(release)
+	remote.mu.Lock()
+	remote.nextFetch = time.Time{}
+	remote.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := ValidateJWTWithKeys(ctx, token, remote); err != nil {
+		t.Errorf("expected the cached key during a refresh, got %v", err)
+	}
+}
+
+func TestRequireAuthWithKeys(t *testing.T) {
+	signers := testSigners(t)
+	issuer := NewKeySet()
+	issuer.AddSigningKey("k1", signers["EdDSA"])
+	server, _ := jwksServer(t, issuer)
+
+	var gotUserID string
+	handler := Chain(func(ctx context.Context, r *http.Request) Response {
+		gotUserID, _ = GetUserID(ctx)
+		return JSON(200, nil)
+	}, RequireAuthWithKeys(NewRemoteKeySet(server.URL, 0)))
+
+	token, _ := issuer.GenerateJWT("user123", time.Hour)
+	req := httptest.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if resp := handler(context.Background(), req).(JSONResponse); resp.StatusCode != 200 || gotUserID != "user123" {
+		t.Errorf("expected 200 for user123, got %d for %q", resp.StatusCode, gotUserID)
+	}
+
+	hmacToken, _ := GenerateJWT("user123", "secret", time.Hour)
+	req.Header.Set("Authorization", "Bearer "+hmacToken)
+	if resp := handler(context.Background(), req).(*HTTPError); resp.Status != 401 {
+		t.Errorf("expected 401 for HMAC token, got %d", resp.Status)
+	}
+}
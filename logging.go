@@ -0,0 +1,97 @@
+package bedrock
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	loggerKey      contextKey = "logger"
+	requestInfoKey contextKey = "requestInfo"
+)
+
+// WithLogger adds a request-scoped logger to the context.
+// Bedrock calls this before every route handler; apps rarely need to.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// Logger returns the request-scoped logger from the context.
+// Inside a route handler it carries the request's request_id, so handler
+// logs can be correlated with the access log line.
+// Outside a request it returns slog.Default().
+//
+// Example:
+//
+//	func MyHandler(ctx context.Context, r *http.Request) bedrock.Response {
+//	    bedrock.Logger(ctx).Info("creating booking", "room", roomID)
+//	    // ...
+//	}
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// requestInfo collects details produced deeper in the handler chain,
+// such as the authenticated user, so the access log can report them.
+type requestInfo struct {
+	userID string
+}
+
+// recordUserID notes userID for the access log, if ctx belongs to a request.
+// WithUserID calls it, so requests an inner middleware rejects after
+// authentication are still attributed.
+func recordUserID(ctx context.Context, userID string) {
+	if info, ok := ctx.Value(requestInfoKey).(*requestInfo); ok {
+		info.userID = userID
+	}
+}
+
+// requestLogging assigns the request ID, wraps next with a request-scoped
+// logger and, unless disabled, writes one access log line per request.
+// route is the Route.Path template.
+func requestLogging(opts Options, method, route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		requestID := incomingRequestID(req, opts.RequestIDHeader)
+		w.Header().Set(opts.RequestIDHeader, requestID)
+
+		info := &requestInfo{}
+		reqLogger := opts.Logger.With("request_id", requestID)
+
+		ctx := req.Context()
+		ctx = WithRequestID(ctx, requestID)
+		ctx = context.WithValue(ctx, requestInfoKey, info)
+		ctx = WithLogger(ctx, reqLogger)
+
+		rec := newResponseRecorder(w)
+		next(rec, req.WithContext(ctx))
+
+		if opts.DisableAccessLog {
+			return
+		}
+
+		level := slog.LevelInfo
+		if rec.Status() >= 500 {
+			level = slog.LevelError
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", method),
+			slog.String("route", route),
+			slog.String("path", req.URL.Path),
+			slog.Int("status", rec.Status()),
+			slog.Int64("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote_addr", req.RemoteAddr),
+		}
+		if info.userID != "" {
+			attrs = append(attrs, slog.String("user_id", info.userID))
+		}
+		reqLogger.LogAttrs(ctx, level, "request", attrs...)
+	}
+}
@@ -0,0 +1,173 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// logLines decodes JSON log output into one map per line.
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+// testRouter builds the main router with a JSON logger writing to buf.
+func testRouter(routes []Route, opts Options, buf *bytes.Buffer) http.Handler {
+	opts.Logger = slog.New(slog.NewJSONHandler(buf, nil))
+	return newRouter(routes, routerConfig{opts: opts.withDefaults()})
+}
+
+func TestAccessLog(t *testing.T) {
+	secret := "test-secret"
+	token, _ := GenerateJWT("user123", secret, time.Hour)
+
+	routes := []Route{{
+		Method:     "GET",
+		Path:       "/items/{id}",
+		Middleware: []Middleware{RequireAuth(secret)},
+		Handler: func(ctx context.Context, r *http.Request) Response {
+			Logger(ctx).Info("loading item")
+			return JSON(200, map[string]string{"id": "42"})
+		},
+	}}
+
+	var buf bytes.Buffer
+	router := testRouter(routes, Options{}, &buf)
+
+	req := httptest.NewRequest("GET", "/items/42", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	lines := logLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("expected handler log and access log, got %d lines", len(lines))
+	}
+	handlerLog, access := lines[0], lines[1]
+
+	if handlerLog["msg"] != "loading item" {
+		t.Errorf("unexpected handler log: %v", handlerLog)
+	}
+	if handlerLog["request_id"] == nil || handlerLog["request_id"] != access["request_id"] {
+		t.Errorf("handler and access logs should share request_id: %v vs %v", handlerLog["request_id"], access["request_id"])
+	}
+
+	want := map[string]any{
+		"msg":     "request",
+		"method":  "GET",
+		"route":   "/items/{id}",
+		"path":    "/items/42",
+		"status":  float64(200),
+		"bytes":   float64(w.Body.Len()),
+		"user_id": "user123",
+	}
+	for key, value := range want {
+		if access[key] != value {
+			t.Errorf("access log %s: got %v, want %v", key, access[key], value)
+		}
+	}
+	for _, key := range []string{"duration", "remote_addr"} {
+		if _, ok := access[key]; !ok {
+			t.Errorf("access log missing %s", key)
+		}
+	}
+}
+
+func TestAccessLog_UserIDOnRejectedRequest(t *testing.T) {
+	secret := "test-secret"
+	token, _ := GenerateJWT("user123", secret, time.Hour)
+	deny := func(next Handler) Handler {
+		return func(ctx context.Context, r *http.Request) Response {
+			return Forbidden("insufficient permissions")
+		}
+	}
+	routes := []Route{{
+		Method:     "DELETE",
+		Path:       "/items/{id}",
+		Middleware: []Middleware{RequireAuth(secret), deny},
+		Handler: func(ctx context.Context, r *http.Request) Response {
+			return JSON(204, nil)
+		},
+	}}
+
+	var buf bytes.Buffer
+	router := testRouter(routes, Options{}, &buf)
+	req := httptest.NewRequest("DELETE", "/items/42", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := logLines(t, &buf)
+	access := lines[len(lines)-1]
+	if access["status"] != float64(403) || access["user_id"] != "user123" {
+		t.Errorf("expected the denied request to be attributed to user123, got %v", access)
+	}
+}
+
+func TestAccessLog_ServerErrorLoggedAtErrorLevel(t *testing.T) {
+	routes := []Route{{
+		Method: "GET",
+		Path:   "/fail",
+		Handler: func(ctx context.Context, r *http.Request) Response {
+			return Error(map[string]string{"error": "boom"})
+		},
+	}}
+
+	var buf bytes.Buffer
+	router := testRouter(routes, Options{}, &buf)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+
+	lines := logLines(t, &buf)
+	if len(lines) != 1 || lines[0]["level"] != "ERROR" {
+		t.Fatalf("expected one ERROR access log line, got %v", lines)
+	}
+	if _, ok := lines[0]["user_id"]; ok {
+		t.Error("unauthenticated request should not log user_id")
+	}
+}
+
+func TestAccessLog_Disabled(t *testing.T) {
+	var sawLogger bool
+	routes := []Route{{
+		Method: "GET",
+		Path:   "/hello",
+		Handler: func(ctx context.Context, r *http.Request) Response {
+			_, sawLogger = ctx.Value(loggerKey).(*slog.Logger)
+			return JSON(200, nil)
+		},
+	}}
+
+	var buf bytes.Buffer
+	router := testRouter(routes, Options{DisableAccessLog: true}, &buf)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hello", nil))
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no access log, got %q", buf.String())
+	}
+	if !sawLogger {
+		t.Error("request-scoped logger should be injected even when access logging is disabled")
+	}
+}
+
+func TestLogger_DefaultOutsideRequest(t *testing.T) {
+	if Logger(context.Background()) != slog.Default() {
+		t.Error("expected slog.Default() outside a request")
+	}
+}
@@ -0,0 +1,453 @@
+package bedrock
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// DefaultBuckets are the default histogram buckets, in seconds.
+// They cover typical HTTP latencies from 5ms to 10s.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultRegistry is the registry used when Options.Metrics is nil.
+// Apps can register their own metrics on it and they will be served
+// alongside bedrock's built-in HTTP metrics.
+var DefaultRegistry = NewRegistry()
+
+// Registry holds metrics and renders them in the Prometheus text exposition format.
+//
+// Registering a metric is idempotent: asking for a metric that already exists
+// with the same type and labels returns the existing one. Registering the same
+// name with a different type or label set is a programming error and panics.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// NewRegistry creates an empty metrics registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// metric is implemented by every metric family held in a Registry.
+type metric interface {
+	desc() *metricDesc
+	write(w io.Writer)
+}
+
+// metricDesc describes a metric family.
+type metricDesc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+// Counter is a monotonically increasing metric, optionally partitioned by labels.
+//
+// Example:
+//
+//	signups := bedrock.DefaultRegistry.Counter("signups_total", "Completed signups.", "plan")
+//	signups.Inc("pro")
+type Counter struct {
+	d      metricDesc
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labelValues []string
+	value       float64
+}
+
+// Gauge is a metric that can go up and down, optionally partitioned by labels.
+type Gauge struct {
+	d      metricDesc
+	mu     sync.Mutex
+	series map[string]*gaugeSeries
+}
+
+type gaugeSeries struct {
+	labelValues []string
+	value       float64
+}
+
+// Histogram counts observations in configurable buckets, optionally partitioned by labels.
+type Histogram struct {
+	d       metricDesc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64 // per bucket, not cumulative
+	count       uint64
+	sum         float64
+}
+
+// Counter returns the counter with the given name, registering it if needed.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	d := metricDesc{name: name, help: help, kind: "counter", labels: labels}
+	return r.register(d, func() metric {
+		return &Counter{d: d, series: make(map[string]*counterSeries)}
+	}).(*Counter)
+}
+
+// Gauge returns the gauge with the given name, registering it if needed.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	d := metricDesc{name: name, help: help, kind: "gauge", labels: labels}
+	return r.register(d, func() metric {
+		return &Gauge{d: d, series: make(map[string]*gaugeSeries)}
+	}).(*Gauge)
+}
+
+// Histogram returns the histogram with the given name, registering it if needed.
+// If buckets is nil, DefaultBuckets is used. Buckets must be sorted in increasing order.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("bedrock: histogram %q buckets are not sorted", name))
+	}
+	d := metricDesc{name: name, help: help, kind: "histogram", labels: labels}
+	return r.register(d, func() metric {
+		return &Histogram{d: d, buckets: buckets, series: make(map[string]*histogramSeries)}
+	}).(*Histogram)
+}
+
+// register returns the existing metric named d.name or stores a new one.
+func (r *Registry) register(d metricDesc, create func() metric) metric {
+	if !validMetricName(d.name) {
+		panic(fmt.Sprintf("bedrock: invalid metric name %q", d.name))
+	}
+	for _, label := range d.labels {
+		if !validMetricName(label) || strings.HasPrefix(label, "__") || label == "le" {
+			panic(fmt.Sprintf("bedrock: invalid label name %q for metric %q", label, d.name))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.metrics[d.name]; ok {
+		ed := existing.desc()
+		if ed.kind != d.kind || strings.Join(ed.labels, ",") != strings.Join(d.labels, ",") {
+			panic(fmt.Sprintf("bedrock: metric %q already registered as %s with labels %v", d.name, ed.kind, ed.labels))
+		}
+		return existing
+	}
+
+	m := create()
+	r.metrics[d.name] = m
+	return m
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format, sorted by name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	for _, m := range metrics {
+		d := m.desc()
+		fmt.Fprintf(cw, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+		fmt.Fprintf(cw, "# TYPE %s %s\n", d.name, d.kind)
+		m.write(cw)
+	}
+	return cw.n, cw.err
+}
+
+// Handler returns an http.Handler serving the registry at any path.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+// Inc increments the counter by 1.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter by v, which must not be negative.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("bedrock: counter %q cannot decrease", c.d.name))
+	}
+	key := c.d.seriesKey(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{labelValues: append([]string(nil), labelValues...)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+func (c *Counter) desc() *metricDesc { return &c.d }
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.d.name, formatLabels(c.d.labels, s.labelValues), formatFloat(s.value))
+	}
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.update(labelValues, func(s *gaugeSeries) { s.value = v })
+}
+
+// Inc increments the gauge by 1.
+func (g *Gauge) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+// Dec decrements the gauge by 1.
+func (g *Gauge) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+// Add adds v to the gauge; v may be negative.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.update(labelValues, func(s *gaugeSeries) { s.value += v })
+}
+
+func (g *Gauge) update(labelValues []string, fn func(s *gaugeSeries)) {
+	key := g.d.seriesKey(labelValues)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	s, ok := g.series[key]
+	if !ok {
+		s = &gaugeSeries{labelValues: append([]string(nil), labelValues...)}
+		g.series[key] = s
+	}
+	fn(s)
+}
+
+func (g *Gauge) desc() *metricDesc { return &g.d }
+
+func (g *Gauge) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, key := range sortedKeys(g.series) {
+		s := g.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", g.d.name, formatLabels(g.d.labels, s.labelValues), formatFloat(s.value))
+	}
+}
+
+// Observe records a single observation.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := h.d.seriesKey(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *Histogram) desc() *metricDesc { return &h.d }
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	bucketLabels := append(append([]string{}, h.d.labels...), "le")
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			labels := formatLabels(bucketLabels, append(append([]string{}, s.labelValues...), formatFloat(upper)))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.d.name, labels, cumulative)
+		}
+		labels := formatLabels(bucketLabels, append(append([]string{}, s.labelValues...), "+Inf"))
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.d.name, labels, s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.d.name, formatLabels(h.d.labels, s.labelValues), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.d.name, formatLabels(h.d.labels, s.labelValues), s.count)
+	}
+}
+
+// seriesKey validates the label values and returns the key of their series.
+func (d *metricDesc) seriesKey(labelValues []string) string {
+	if len(labelValues) != len(d.labels) {
+		panic(fmt.Sprintf("bedrock: metric %q expects %d label values, got %d", d.name, len(d.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// --- Built-in HTTP metrics ---
+
+// httpMetrics holds bedrock's built-in per-route metrics.
+type httpMetrics struct {
+	requests *Counter
+	duration *Histogram
+	inFlight *Gauge
+}
+
+func newHTTPMetrics(registry *Registry) *httpMetrics {
+	return &httpMetrics{
+		requests: registry.Counter("http_requests_total", "Total HTTP requests by route template, method and status.", "method", "route", "status"),
+		duration: registry.Histogram("http_request_duration_seconds", "HTTP request latency by route template and method.", nil, "method", "route"),
+		inFlight: registry.Gauge("http_requests_in_flight", "HTTP requests currently being served by route template and method.", "method", "route"),
+	}
+}
+
+// instrument wraps next with request counting, latency and in-flight tracking.
+// route is the Route.Path template, so path parameters don't explode cardinality.
+func (m *httpMetrics) instrument(method, route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		m.inFlight.Inc(method, route)
+		defer m.inFlight.Dec(method, route)
+
+		start := time.Now()
+		rec := newResponseRecorder(w)
+		next(rec, req)
+
+		m.duration.Observe(time.Since(start).Seconds(), method, route)
+		m.requests.Inc(method, route, strconv.Itoa(rec.Status()))
+	}
+}
+
+// registerMetricsEndpoint adds /metrics to router.
+func registerMetricsEndpoint(router *mux.Router, registry *Registry) {
+	router.Handle("/metrics", registry.Handler())
+}
+
+// newMetricsServer returns an unstarted server exposing only /metrics.
+func newMetricsServer(port string, registry *Registry) *http.Server {
+	router := mux.NewRouter()
+	registerMetricsEndpoint(router, registry)
+
+	return &http.Server{
+		Addr:    ":" + port,
+		Handler: router,
+	}
+}
+
+// --- Exposition format helpers ---
+
+func validMetricName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(values[i]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(v string) string { return labelValueEscaper.Replace(v) }
+
+func escapeHelp(v string) string { return helpEscaper.Replace(v) }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// countingWriter tracks bytes written and the first write error,
+// so metric writers don't need to check every Fprintf.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	cw.err = err
+	return n, err
+}
@@ -0,0 +1,164 @@
+package bedrock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Jack4Code/bedrock/config"
+)
+
+func TestRegistryExposition(t *testing.T) {
+	registry := NewRegistry()
+
+	jobs := registry.Counter("jobs_total", "Jobs processed.", "queue")
+	jobs.Inc("email")
+	jobs.Add(2, "email")
+	jobs.Inc(`we"ird`)
+
+	queue := registry.Gauge("queue_depth", "Items waiting.")
+	queue.Set(5)
+	queue.Dec()
+
+	latency := registry.Histogram("job_seconds", "Job latency.", []float64{0.1, 1})
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(3)
+
+	var b strings.Builder
+	if _, err := registry.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+
+	expected := `# HELP job_seconds Job latency.
+# TYPE job_seconds histogram
+job_seconds_bucket{le="0.1"} 1
+job_seconds_bucket{le="1"} 2
+job_seconds_bucket{le="+Inf"} 3
+job_seconds_sum 3.55
+job_seconds_count 3
+# HELP jobs_total Jobs processed.
+# TYPE jobs_total counter
+jobs_total{queue="email"} 3
+jobs_total{queue="we\"ird"} 1
+# HELP queue_depth Items waiting.
+# TYPE queue_depth gauge
+queue_depth 4
+`
+	if b.String() != expected {
+		t.Errorf("unexpected exposition:\n%s\nwant:\n%s", b.String(), expected)
+	}
+}
+
+func TestRegistryRegistrationIsIdempotent(t *testing.T) {
+	registry := NewRegistry()
+
+	first := registry.Counter("hits_total", "Hits.", "page")
+	second := registry.Counter("hits_total", "Hits.", "page")
+	if first != second {
+		t.Error("registering the same counter twice should return the existing one")
+	}
+}
+
+func TestRegistryConflictingRegistrationPanics(t *testing.T) {
+	registry := NewRegistry()
+	registry.Counter("hits_total", "Hits.", "page")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic when re-registering with a different type")
+		}
+	}()
+	registry.Gauge("hits_total", "Hits.", "page")
+}
+
+func TestLabelCountMismatchPanics(t *testing.T) {
+	counter := NewRegistry().Counter("hits_total", "Hits.", "page")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for missing label value")
+		}
+	}()
+	counter.Inc()
+}
+
+func TestRunContext_ServesMetrics(t *testing.T) {
+	httpPort, metricsPort := freePort(t), freePort(t)
+	app := &testApp{routes: []Route{helloRoute()}}
+
+	opts := quietOptions()
+	opts.Metrics = NewRegistry()
+	opts.Metrics.Counter("app_widgets_total", "Widgets made.").Inc()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := config.BaseConfig{HTTPPort: httpPort, HealthPort: httpPort, MetricsPort: metricsPort}
+	done := runInBackground(ctx, app, cfg, opts)
+	defer func() {
+		cancel()
+		waitForResult(t, done)
+	}()
+
+	waitForStatus(t, fmt.Sprintf("http://127.0.0.1:%d/hello", httpPort), 200)
+	waitForStatus(t, fmt.Sprintf("http://127.0.0.1:%d/metrics", metricsPort), 200)
+
+	resp, err := testClient.Get(fmt.Sprintf("http://127.0.0.1:%d/metrics", metricsPort))
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+		t.Errorf("Content-Type: got %q, want text/plain", got)
+	}
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/hello",status="200"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/hello"} 1`,
+		`http_requests_in_flight{method="GET",route="/hello"} 0`,
+		`app_widgets_total 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics output missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestRunContext_MetricsMergedIntoMainServer(t *testing.T) {
+	port := freePort(t)
+	app := &testApp{routes: []Route{helloRoute()}}
+
+	opts := quietOptions()
+	opts.Metrics = NewRegistry()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := config.BaseConfig{HTTPPort: port, HealthPort: port, MetricsPort: port}
+	done := runInBackground(ctx, app, cfg, opts)
+	defer func() {
+		cancel()
+		waitForResult(t, done)
+	}()
+
+	waitForStatus(t, fmt.Sprintf("http://127.0.0.1:%d/metrics", port), http.StatusOK)
+}
+
+func TestRunContext_MetricsRouteConflict(t *testing.T) {
+	port := freePort(t)
+	app := &testApp{routes: []Route{{
+		Method:  "GET",
+		Path:    "/metrics",
+		Handler: helloRoute().Handler,
+	}}}
+
+	opts := quietOptions()
+	opts.Metrics = NewRegistry()
+
+	cfg := config.BaseConfig{HTTPPort: port, HealthPort: port, MetricsPort: port}
+	err := RunContext(context.Background(), app, cfg, opts)
+	if err == nil || !strings.Contains(err.Error(), "route conflict") {
+		t.Fatalf("expected route conflict error, got %v", err)
+	}
+}
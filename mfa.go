@@ -0,0 +1,302 @@
+package bedrock
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Defaults used by TOTPConfig when its fields are zero, and by
+// GenerateMFAPendingToken.
+const (
+	DefaultTOTPPeriod    = 30 * time.Second
+	DefaultTOTPDigits    = 6
+	DefaultTOTPSkew      = 1
+	DefaultMFAPendingTTL = 5 * time.Minute
+)
+
+var (
+	// ErrInvalidTOTP is returned by TOTPConfig.Verify for wrong codes.
+	ErrInvalidTOTP = errors.New("bedrock: invalid TOTP code")
+	// ErrTOTPReplayed is returned by TOTPConfig.Verify for a code that was
+	// already used.
+	ErrTOTPReplayed = errors.New("bedrock: TOTP code already used")
+)
+
+// totpEncoding is the base32 alphabet of TOTP secrets, as authenticator
+// apps expect them.
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPConfig configures time-based one-time passwords (RFC 6238), as shown
+// by authenticator apps. Codes use HMAC-SHA1, the only algorithm all
+// common apps support.
+type TOTPConfig struct {
+	Issuer string        // shown in authenticator apps, e.g. "Bookings"
+	Period time.Duration // optional; whole seconds, defaults to DefaultTOTPPeriod
+	Digits int           // optional; 6 to 8, defaults to DefaultTOTPDigits
+	// Skew is the number of periods accepted before and after the current
+	// one. Optional; 0 means DefaultTOTPSkew, and -1 accepts the current
+	// period only.
+	Skew int
+}
+
+// withDefaults fills in the defaults and rejects settings authenticator
+// apps do not support.
+func (cfg TOTPConfig) withDefaults() (TOTPConfig, error) {
+	if cfg.Period == 0 {
+		cfg.Period = DefaultTOTPPeriod
+	}
+	if cfg.Digits == 0 {
+		cfg.Digits = DefaultTOTPDigits
+	}
+	switch {
+	case cfg.Skew == 0:
+		cfg.Skew = DefaultTOTPSkew
+	case cfg.Skew < 0:
+		cfg.Skew = 0
+	}
+
+	if cfg.Digits < 6 || cfg.Digits > 8 {
+		return cfg, fmt.Errorf("bedrock: TOTP digits must be 6 to 8, got %d", cfg.Digits)
+	}
+	if cfg.Period < time.Second || cfg.Period%time.Second != 0 {
+		return cfg, fmt.Errorf("bedrock: TOTP period must be a whole number of seconds, got %s", cfg.Period)
+	}
+	return cfg, nil
+}
+
+// GenerateTOTPSecret returns a new random 160-bit TOTP secret, base32
+// encoded. Store it encrypted, or at least not next to the password hash:
+// unlike a password, it cannot be hashed.
+func GenerateTOTPSecret() string {
+	secret := make([]byte, 20)
+	rand.Read(secret)
+	return totpEncoding.EncodeToString(secret)
+}
+
+// URI returns the otpauth:// URI that enrolls secret in an authenticator
+// app, usually shown as a QR code. account identifies the user, e.g. their
+// email address.
+func (cfg TOTPConfig) URI(secret, account string) (string, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return "", err
+	}
+	label := account
+	if cfg.Issuer != "" {
+		label = cfg.Issuer + ":" + account
+	}
+
+	query := url.Values{
+		"secret":    {secret},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(cfg.Digits)},
+		"period":    {fmt.Sprint(int(cfg.Period.Seconds()))},
+	}
+	if cfg.Issuer != "" {
+		query.Set("issuer", cfg.Issuer)
+	}
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: strings.ReplaceAll(query.Encode(), "+", "%20"),
+	}
+	return uri.String(), nil
+}
+
+// Code returns the code for secret at time t.
+func (cfg TOTPConfig) Code(secret string, t time.Time) (string, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return "", err
+	}
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return totpCode(key, t.Unix()/int64(cfg.Period.Seconds()), cfg.Digits), nil
+}
+
+// Verify checks code against secret, accepting codes up to Skew periods
+// early or late. lastStep is the step Verify returned for the last code
+// accepted for this secret, or 0; that code and older ones are rejected
+// with ErrTOTPReplayed. On success Verify returns the step of code, which
+// the caller must store as the new lastStep, atomically so that two
+// concurrent requests cannot both use the same code.
+//
+// Example:
+//
+//	step, err := totp.Verify(user.TOTPSecret, req.Code, user.TOTPLastStep)
+//	if err != nil {
+//	    return bedrock.Unauthorized("invalid code")
+//	}
+//	// UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1
+//	ok, err := a.db.SetTOTPLastStep(ctx, user.ID, step)
+func (cfg TOTPConfig) Verify(secret, code string, lastStep int64) (int64, error) {
+	cfg, err := cfg.withDefaults()
+	if err != nil {
+		return 0, err
+	}
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().Unix() / int64(cfg.Period.Seconds())
+	code = strings.ReplaceAll(code, " ", "")
+	for step := now - int64(cfg.Skew); step <= now+int64(cfg.Skew); step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step, cfg.Digits)), []byte(code)) != 1 {
+			continue
+		}
+		if step <= lastStep {
+			return 0, ErrTOTPReplayed
+		}
+		return step, nil
+	}
+	return 0, ErrInvalidTOTP
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := totpEncoding.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("bedrock: invalid TOTP secret")
+	}
+	return key, nil
+}
+
+// totpCode computes the HOTP value (RFC 4226) of key for counter step.
+func totpCode(key []byte, step int64, digits int) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulus := uint32(1)
+	for range digits {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%modulus)
+}
+
+// GenerateRecoveryCodes returns n single-use recovery codes, to show to the
+// user once, and their hashes, to store. Each code carries 60 random bits,
+// formatted like "7GQ2-M4XK-3Z5A".
+func GenerateRecoveryCodes(n int) (codes, hashes []string) {
+	codes, hashes = make([]string, n), make([]string, n)
+	for i := range n {
+		text := rand.Text()
+		codes[i] = text[0:4] + "-" + text[4:8] + "-" + text[8:12]
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+	return codes, hashes
+}
+
+// CheckRecoveryCode returns the index in hashes of code's hash, or -1 if
+// code is not one of them. Case, spaces and dashes are ignored. Delete the
+// matched hash, atomically, so the code cannot be used again.
+//
+// Example:
+//
+//	i := bedrock.CheckRecoveryCode(req.Code, user.RecoveryCodeHashes)
+//	if i < 0 {
+//	    return bedrock.Unauthorized("invalid code")
+//	}
+//	// DELETE FROM recovery_codes WHERE user_id = $1 AND hash = $2
+//	ok, err := a.db.DeleteRecoveryCode(ctx, user.ID, user.RecoveryCodeHashes[i])
+func CheckRecoveryCode(code string, hashes []string) int {
+	hash := []byte(hashToken(normalizeRecoveryCode(code)))
+	match := -1
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare(hash, []byte(h)) == 1 {
+			match = i
+		}
+	}
+	return match
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// mfaPendingClaims are the claims of an MFA pending token.
+type mfaPendingClaims struct {
+	jwt.RegisteredClaims
+	MFAPending bool `json:"mfa_pending"`
+}
+
+// GenerateMFAPendingToken issues a token proving that userID passed the
+// first factor of a two-step login, usually their password. It is signed
+// according to cfg like access tokens, but RequireAuth and the other
+// validators refuse it; only ValidateMFAPendingToken accepts it. An
+// expiration of 0 uses DefaultMFAPendingTTL.
+//
+// Example:
+//
+//	func (a *MyApp) login(ctx context.Context, r *http.Request) bedrock.Response {
+//	    user, err := a.authenticate(ctx, r)
+//	    if err != nil {
+//	        return bedrock.Unauthorized("invalid credentials")
+//	    }
+//	    if user.TOTPSecret != "" {
+//	        pending, err := bedrock.GenerateMFAPendingToken(user.ID, a.tokens, 0)
+//	        if err != nil {
+//	            return bedrock.FromError(err)
+//	        }
+//	        return bedrock.JSON(200, map[string]any{"mfa_required": true, "mfa_token": pending})
+//	    }
+//	    // issue tokens as usual
+//	}
+func GenerateMFAPendingToken(userID string, cfg TokenConfig, expiration time.Duration) (string, error) {
+	if expiration == 0 {
+		expiration = DefaultMFAPendingTTL
+	}
+	return GenerateToken(mfaPendingClaims{
+		RegisteredClaims: NewRegisteredClaims(userID, expiration),
+		MFAPending:       true,
+	}, cfg)
+}
+
+// ValidateMFAPendingToken validates a token from GenerateMFAPendingToken
+// and returns its user ID. After checking the second factor, issue the
+// user's real tokens.
+//
+// Codes are short, so rate-limit the endpoint that calls this, e.g. to a
+// few attempts per user and pending token.
+//
+// Example:
+//
+//	func (a *MyApp) verifyMFA(ctx context.Context, r *http.Request) bedrock.Response {
+//	    var req mfaRequest
+//	    if err := bedrock.DecodeAndValidate(r, &req, 0); err != nil {
+//	        return bedrock.FromError(err)
+//	    }
+//	    userID, err := bedrock.ValidateMFAPendingToken(ctx, req.MFAToken, a.tokens)
+//	    if err != nil {
+//	        return bedrock.Unauthorized("invalid token")
+//	    }
+//	    if err := a.checkSecondFactor(ctx, userID, req.Code); err != nil {
+//	        return bedrock.Unauthorized("invalid code")
+//	    }
+//	    pair, err := a.refresh.Issue(ctx, userID)
+//	    // ...
+//	}
+func ValidateMFAPendingToken(ctx context.Context, tokenString string, cfg TokenConfig) (string, error) {
+	cfg.mfaPending = true
+	return validateSubject(ctx, tokenString, cfg)
+}
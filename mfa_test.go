@@ -0,0 +1,166 @@
+package bedrock
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTP_RFC6238Vectors(t *testing.T) {
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // base32 of "12345678901234567890"
+	cfg := TOTPConfig{Digits: 8}
+	for unix, want := range map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	} {
+		if got, err := cfg.Code(secret, time.Unix(unix, 0)); err != nil || got != want {
+			t.Errorf("T=%d: got %q, %v, want %q", unix, got, err, want)
+		}
+	}
+}
+
+func TestTOTP_Verify(t *testing.T) {
+	cfg := TOTPConfig{Issuer: "Bookings"}
+	secret := GenerateTOTPSecret()
+	if len(secret) != 32 || secret == GenerateTOTPSecret() {
+		t.Fatalf("unexpected secret %q", secret)
+	}
+
+	now := time.Now()
+	code, _ := cfg.Code(secret, now)
+	step, err := cfg.Verify(secret, code, 0)
+	if err != nil || step != now.Unix()/30 {
+		t.Fatalf("expected current code to verify, got %d, %v", step, err)
+	}
+	if _, err := cfg.Verify(secret, code, step); err != ErrTOTPReplayed {
+		t.Errorf("expected replayed code to be rejected, got %v", err)
+	}
+
+	previous, _ := cfg.Code(secret, now.Add(-30*time.Second))
+	if _, err := cfg.Verify(secret, previous, 0); err != nil {
+		t.Errorf("expected code within the skew to verify, got %v", err)
+	}
+	if _, err := cfg.Verify(secret, previous, step); err != ErrTOTPReplayed {
+		t.Errorf("expected code older than the last used one to be rejected, got %v", err)
+	}
+
+	old, _ := cfg.Code(secret, now.Add(-2*time.Minute))
+	if _, err := cfg.Verify(secret, old, 0); err != ErrInvalidTOTP {
+		t.Errorf("expected code outside the skew to be rejected, got %v", err)
+	}
+	if _, err := cfg.Verify("not base32!", code, 0); err == nil {
+		t.Error("expected error for an invalid secret")
+	}
+
+	// Skew -1 accepts the current period only
+	strict := TOTPConfig{Skew: -1}
+	if _, err := strict.Verify(secret, previous, 0); err != ErrInvalidTOTP {
+		t.Errorf("expected code from the previous period to be rejected, got %v", err)
+	}
+	if _, err := strict.Verify(secret, code, 0); err != nil {
+		t.Errorf("expected current code to verify, got %v", err)
+	}
+}
+
+func TestTOTPConfig_Invalid(t *testing.T) {
+	secret := GenerateTOTPSecret()
+	for _, cfg := range []TOTPConfig{{Digits: 5}, {Digits: 9}, {Period: 500 * time.Millisecond}, {Period: 1500 * time.Millisecond}} {
+		if _, err := cfg.Code(secret, time.Now()); err == nil {
+			t.Errorf("%+v: expected Code to fail", cfg)
+		}
+		if _, err := cfg.Verify(secret, "123456", 0); err == nil || err == ErrInvalidTOTP {
+			t.Errorf("%+v: expected a configuration error from Verify, got %v", cfg, err)
+		}
+		if _, err := cfg.URI(secret, "ada@example.com"); err == nil {
+			t.Errorf("%+v: expected URI to fail", cfg)
+		}
+	}
+}
+
+func TestTOTP_URI(t *testing.T) {
+	uri, err := TOTPConfig{Issuer: "Acme Bookings"}.URI("JBSWY3DPEHPK3PXP", "ada@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(uri, "otpauth://totp/Acme%20Bookings:ada@example.com?") {
+		t.Errorf("unexpected label in %q", uri)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("secret") != "JBSWY3DPEHPK3PXP" || query.Get("issuer") != "Acme Bookings" ||
+		query.Get("digits") != "6" || query.Get("period") != "30" || query.Get("algorithm") != "SHA1" {
+		t.Errorf("unexpected parameters: %v", query)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes := GenerateRecoveryCodes(10)
+	if len(codes) != 10 || len(hashes) != 10 {
+		t.Fatalf("expected 10 codes, got %d", len(codes))
+	}
+	if len(codes[0]) != 14 || codes[0][4] != '-' || codes[0] == codes[1] {
+		t.Errorf("unexpected codes: %v", codes[:2])
+	}
+	if strings.Contains(hashes[3], strings.ReplaceAll(codes[3], "-", "")) {
+		t.Error("code stored in plain text")
+	}
+
+	if i := CheckRecoveryCode(codes[3], hashes); i != 3 {
+		t.Errorf("expected index 3, got %d", i)
+	}
+	typed := strings.ToLower(strings.ReplaceAll(codes[7], "-", " "))
+	if i := CheckRecoveryCode(typed, hashes); i != 7 {
+		t.Errorf("expected lenient matching of %q, got %d", typed, i)
+	}
+	if i := CheckRecoveryCode("AAAA-BBBB-CCCC", hashes); i != -1 {
+		t.Errorf("expected -1 for unknown code, got %d", i)
+	}
+}
+
+func TestMFAPendingToken(t *testing.T) {
+	cfg := TokenConfig{Secret: "test-secret"}
+	ctx := context.Background()
+	pending, err := GenerateMFAPendingToken("user123", cfg, 0)
+	if err != nil {
+		t.Fatalf("GenerateMFAPendingToken failed: %v", err)
+	}
+
+	// Only the second step accepts it
+	if userID, err := ValidateMFAPendingToken(ctx, pending, cfg); err != nil || userID != "user123" {
+		t.Errorf("got %q, %v", userID, err)
+	}
+	if _, err := ValidateJWT(pending, "test-secret"); err == nil {
+		t.Error("expected ValidateJWT to refuse a pending token")
+	}
+
+	handler := Chain(func(ctx context.Context, r *http.Request) Response {
+		return JSON(200, nil)
+	}, RequireAuth("test-secret"))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+pending)
+	if resp := handler(ctx, req).(*HTTPError); resp.Status != 401 {
+		t.Errorf("expected RequireAuth to refuse a pending token, got %d", resp.Status)
+	}
+
+	// A full token is no substitute for a pending one
+	full, _ := GenerateJWT("user123", "test-secret", time.Hour)
+	if _, err := ValidateMFAPendingToken(ctx, full, cfg); err == nil {
+		t.Error("expected ValidateMFAPendingToken to refuse an access token")
+	}
+
+	expired, _ := GenerateMFAPendingToken("user123", cfg, -time.Minute)
+	if _, err := ValidateMFAPendingToken(ctx, expired, cfg); err == nil {
+		t.Error("expected expired pending token to be refused")
+	}
+}
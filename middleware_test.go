@@ -0,0 +1,142 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// tagHTTPMiddleware records name when it runs.
+func tagHTTPMiddleware(name string, order *[]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*order = append(*order, name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// globalMiddlewareRouter builds a router with merged health endpoints and the given options.
+func globalMiddlewareRouter(routes []Route, opts Options) http.Handler {
+	var buf bytes.Buffer
+	opts.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+	status := newHealthStatus()
+	status.SetHealthy(true)
+	status.SetReady(true)
+	return newRouter(routes, routerConfig{opts: opts.withDefaults(), health: status})
+}
+
+func TestOptionsMiddleware_Order(t *testing.T) {
+	var order []string
+	route := helloRoute()
+	route.Middleware = []Middleware{tagMiddleware("route", &order)}
+
+	router := globalMiddlewareRouter([]Route{route}, Options{
+		Middleware:     []Middleware{tagMiddleware("mw1", &order), tagMiddleware("mw2", &order)},
+		HTTPMiddleware: []func(http.Handler) http.Handler{tagHTTPMiddleware("http1", &order), tagHTTPMiddleware("http2", &order)},
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/hello", nil))
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	want := []string{"http1", "http2", "mw1", "mw2", "route"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("order: got %v, want %v", order, want)
+	}
+}
+
+func TestOptionsMiddleware_SkipsInfrastructureAndPreflight(t *testing.T) {
+	var order []string
+	router := globalMiddlewareRouter([]Route{helloRoute()}, Options{
+		Middleware:     []Middleware{tagMiddleware("mw", &order)},
+		HTTPMiddleware: []func(http.Handler) http.Handler{tagHTTPMiddleware("http", &order)},
+	})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/health", nil),
+		httptest.NewRequest("GET", "/ready", nil),
+		httptest.NewRequest("OPTIONS", "/hello", nil),
+		httptest.NewRequest("GET", "/missing", nil),
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if len(order) != 0 {
+			t.Errorf("%s %s: global middleware ran: %v", req.Method, req.URL.Path, order)
+			order = nil
+		}
+	}
+}
+
+func TestHTTPMiddleware_RunsInsideCORSAndRequestScope(t *testing.T) {
+	var requestID string
+	var sawErrorConfig bool
+	reject := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID, _ = GetRequestID(r.Context())
+			_, sawErrorConfig = r.Context().Value(errorConfigKey).(errorConfig)
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+	}
+	router := globalMiddlewareRouter([]Route{helloRoute()}, Options{
+		HTTPMiddleware: []func(http.Handler) http.Handler{reject},
+	})
+
+	req := httptest.NewRequest("GET", "/hello", nil)
+	req.Header.Set("Origin", "https://app.example")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") == "" {
+		t.Error("CORS headers should be set on responses from HTTP middleware")
+	}
+	if requestID == "" || requestID != w.Header().Get(DefaultRequestIDHeader) {
+		t.Errorf("HTTP middleware should see the request ID, got %q", requestID)
+	}
+	if !sawErrorConfig {
+		t.Error("HTTP middleware should see the error config, so FromError works")
+	}
+}
+
+func TestHTTPMiddleware_PanicIsRecovered(t *testing.T) {
+	boom := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+	}
+	router := globalMiddlewareRouter([]Route{helloRoute()}, Options{
+		HTTPMiddleware: []func(http.Handler) http.Handler{boom},
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/hello", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+}
+
+func TestOptionsMiddleware_CanShortCircuit(t *testing.T) {
+	deny := func(next Handler) Handler {
+		return func(ctx context.Context, r *http.Request) Response {
+			return FromError(Forbidden("maintenance"))
+		}
+	}
+	router := globalMiddlewareRouter([]Route{helloRoute()}, Options{Middleware: []Middleware{deny}})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/hello", nil))
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", w.Code)
+	}
+}
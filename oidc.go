@@ -0,0 +1,421 @@
+package bedrock
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultOIDCCookie is the cookie holding the state of a login in progress.
+const DefaultOIDCCookie = "oidc_login"
+
+// oidcLoginTimeout is how long a user has to complete a login at the provider.
+const oidcLoginTimeout = 10 * time.Minute
+
+// OIDCProvider is an OpenID Connect provider, usually created with
+// DiscoverOIDC.
+type OIDCProvider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	Keys   KeyProvider  `json:"-"` // verifies ID tokens; DiscoverOIDC sets a RemoteKeySet for JWKSURI
+	Client *http.Client `json:"-"` // optional; defaults to a client with a 10s timeout
+}
+
+// DiscoverOIDC fetches the discovery document of the provider with the
+// given issuer URL, e.g. "https://accounts.google.com".
+//
+// Example:
+//
+//	google, err := bedrock.DiscoverOIDC(ctx, "https://accounts.google.com")
+func DiscoverOIDC(ctx context.Context, issuer string) (*OIDCProvider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	discoveryURL := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock: fetching OIDC discovery document: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bedrock: fetching OIDC discovery document: unexpected status %d", resp.StatusCode)
+	}
+
+	provider := &OIDCProvider{}
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, 1<<20)).Decode(provider); err != nil {
+		return nil, fmt.Errorf("bedrock: decoding OIDC discovery document: %w", err)
+	}
+	// Tokens are checked against the issuer, so it must be the one asked for
+	if provider.Issuer != issuer {
+		return nil, fmt.Errorf("bedrock: OIDC issuer mismatch: got %q, want %q", provider.Issuer, issuer)
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.JWKSURI == "" {
+		return nil, errors.New("bedrock: OIDC discovery document is missing endpoints")
+	}
+	provider.Keys = NewRemoteKeySet(provider.JWKSURI, 0)
+	return provider, nil
+}
+
+// OIDCClaims are the claims of an ID token.
+type OIDCClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string `json:"nonce,omitempty"`
+	AuthorizedParty string `json:"azp,omitempty"`
+	Email           string `json:"email,omitempty"`
+	EmailVerified   bool   `json:"email_verified,omitempty"`
+	Name            string `json:"name,omitempty"`
+	Picture         string `json:"picture,omitempty"`
+}
+
+// OIDCConfig configures login with an OpenID Connect provider, using the
+// authorization code flow with PKCE.
+//
+// Example:
+//
+//	google, err := bedrock.DiscoverOIDC(ctx, "https://accounts.google.com")
+//	login := bedrock.OIDCConfig{
+//	    Provider:     google,
+//	    ClientID:     cfg.GoogleClientID,
+//	    ClientSecret: cfg.GoogleClientSecret,
+//	    RedirectURL:  "https://app.example.com/auth/google/callback",
+//	    CookieKey:    cfg.OIDCCookieKey, // 32 random bytes
+//	    MapUser: func(ctx context.Context, claims bedrock.OIDCClaims) (string, error) {
+//	        return a.db.UpsertUserByIdentity(ctx, claims.Issuer, claims.Subject, claims.Email)
+//	    },
+//	}
+//
+//	routes := []bedrock.Route{
+//	    {Method: "GET", Path: "/auth/google/login", Handler: login.LoginHandler()},
+//	    {Method: "GET", Path: "/auth/google/callback", Handler: login.CallbackHandler()},
+//	}
+type OIDCConfig struct {
+	Provider     *OIDCProvider
+	ClientID     string
+	ClientSecret string   // optional; public clients rely on PKCE alone
+	RedirectURL  string   // the callback URL registered with the provider
+	Scopes       []string // optional; defaults to openid, email and profile
+
+	// MapUser returns the user ID for a verified identity, e.g. by finding
+	// or creating the user with the claims' Issuer and Subject. An
+	// *HTTPError it returns is sent to the client.
+	MapUser func(ctx context.Context, claims OIDCClaims) (string, error)
+
+	// Tokens, if set, makes the callback respond with a TokenPair for the
+	// user. Otherwise it logs the user in to the session and redirects; the
+	// callback then needs the Sessions middleware, and returns a 500
+	// without it.
+	Tokens *RefreshConfig
+
+	// CookieKey encrypts and authenticates the login state cookie with
+	// AES-256-GCM, so clients can neither read nor alter it. It must be 32
+	// random bytes, shared by every instance of the app.
+	CookieKey []byte
+
+	CookieName string // optional; defaults to DefaultOIDCCookie
+	Insecure   bool   // optional; omit the Secure cookie flag, for local development over HTTP
+}
+
+// oidcLogin is the state of a login in progress, kept in an encrypted
+// cookie between the redirect to the provider and the callback.
+type oidcLogin struct {
+	State     string `json:"s"`
+	Nonce     string `json:"n"`
+	Verifier  string `json:"v"` // PKCE code verifier
+	ReturnTo  string `json:"r"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// LoginHandler redirects the user to the provider to log in. The optional
+// return_to query parameter, a path on this site, is where the callback
+// redirects to afterwards.
+func (cfg OIDCConfig) LoginHandler() Handler {
+	return func(ctx context.Context, r *http.Request) Response {
+		aead, err := cfg.aead()
+		if err != nil {
+			return InternalError().Wrap(err)
+		}
+
+		login := oidcLogin{ReturnTo: "/", ExpiresAt: time.Now().Add(oidcLoginTimeout).Unix()}
+		if returnTo := r.URL.Query().Get("return_to"); isLocalPath(returnTo) {
+			login.ReturnTo = returnTo
+		}
+		for _, field := range []*string{&login.State, &login.Nonce, &login.Verifier} {
+			value, err := randomToken(32)
+			if err != nil {
+				return InternalError().Wrap(err)
+			}
+			*field = value
+		}
+
+		authURL, err := url.Parse(cfg.Provider.AuthorizationEndpoint)
+		if err != nil {
+			return InternalError().Wrap(err)
+		}
+		challenge := sha256.Sum256([]byte(login.Verifier))
+		query := authURL.Query()
+		query.Set("response_type", "code")
+		query.Set("client_id", cfg.ClientID)
+		query.Set("redirect_uri", cfg.RedirectURL)
+		query.Set("scope", strings.Join(cfg.scopes(), " "))
+		query.Set("state", login.State)
+		query.Set("nonce", login.Nonce)
+		query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+		query.Set("code_challenge_method", "S256")
+		authURL.RawQuery = query.Encode()
+
+		value, err := sealLogin(aead, login)
+		if err != nil {
+			return InternalError().Wrap(err)
+		}
+		cookie := cfg.cookie(value, int(oidcLoginTimeout.Seconds()))
+		return cookieResponse{Response: redirect(authURL.String()), cookie: cookie}
+	}
+}
+
+// CallbackHandler completes the login when the provider redirects back:
+// it checks the state, exchanges the code for an ID token, verifies the
+// token's signature, issuer, audience, expiry and nonce, and passes its
+// claims to MapUser. It then responds with a TokenPair if Tokens is set, or
+// logs the user in to the session and redirects to the return_to path.
+func (cfg OIDCConfig) CallbackHandler() Handler {
+	return func(ctx context.Context, r *http.Request) Response {
+		// The login state is single-use, so clear it whatever happens
+		return cookieResponse{Response: cfg.callback(ctx, r), cookie: cfg.cookie("", -1)}
+	}
+}
+
+func (cfg OIDCConfig) callback(ctx context.Context, r *http.Request) Response {
+	aead, err := cfg.aead()
+	if err != nil {
+		return InternalError().Wrap(err)
+	}
+	// Without Sessions, Login would write to a session that is never saved
+	if _, ok := sessionFromContext(ctx); !ok && cfg.Tokens == nil {
+		return InternalError().Wrap(errors.New("bedrock: OIDC session login needs the Sessions middleware before CallbackHandler"))
+	}
+
+	login, ok := cfg.login(aead, r)
+	if !ok {
+		return BadRequest("login expired, please try again")
+	}
+
+	query := r.URL.Query()
+	if code := query.Get("error"); code != "" {
+		Logger(ctx).Warn("OIDC login failed", "error", code, "description", query.Get("error_description"))
+		return Unauthorized("login failed")
+	}
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(login.State)) != 1 {
+		return BadRequest("invalid login state")
+	}
+
+	idToken, err := cfg.exchange(ctx, query.Get("code"), login.Verifier)
+	var oidcErr *oidcError
+	if errors.As(err, &oidcErr) {
+		Logger(ctx).Warn("OIDC code exchange failed", "error", oidcErr.Code, "description", oidcErr.Description)
+		return Unauthorized("login failed")
+	}
+	if err != nil {
+		return InternalError().Wrap(err)
+	}
+
+	claims, err := cfg.verify(ctx, idToken, login.Nonce)
+	if err != nil {
+		Logger(ctx).Warn("OIDC ID token rejected", "error", err)
+		return Unauthorized("login failed")
+	}
+
+	userID, err := cfg.MapUser(ctx, claims)
+	if err != nil {
+		return FromError(err)
+	}
+
+	if cfg.Tokens != nil {
+		pair, err := cfg.Tokens.Issue(ctx, userID)
+		if err != nil {
+			return FromError(err)
+		}
+		return JSONWithHeaders(http.StatusOK, pair, http.Header{"Cache-Control": {"no-store"}})
+	}
+	GetSession(ctx).Login(userID)
+	return redirect(login.ReturnTo)
+}
+
+// login decrypts the login state cookie. It reports false if the cookie is
+// missing, altered or expired.
+func (cfg OIDCConfig) login(aead cipher.AEAD, r *http.Request) (oidcLogin, bool) {
+	var login oidcLogin
+	c, err := r.Cookie(cfg.cookieName())
+	if err != nil {
+		return login, false
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return login, false
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	value, err := aead.Open(nil, nonce, ciphertext, []byte("bedrock-oidc"))
+	if err != nil || json.Unmarshal(value, &login) != nil || login.State == "" {
+		return login, false
+	}
+	if time.Now().Unix() > login.ExpiresAt {
+		return login, false
+	}
+	return login, true
+}
+
+// sealLogin encrypts login into a cookie value.
+func sealLogin(aead cipher.AEAD, login oidcLogin) (string, error) {
+	plaintext, err := json.Marshal(login)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, []byte("bedrock-oidc"))), nil
+}
+
+// aead returns the cipher of the login state cookie.
+func (cfg OIDCConfig) aead() (cipher.AEAD, error) {
+	if len(cfg.CookieKey) != 32 {
+		return nil, fmt.Errorf("bedrock: OIDCConfig.CookieKey must be 32 bytes, got %d", len(cfg.CookieKey))
+	}
+	return newGCM(cfg.CookieKey)
+}
+
+// oidcError is an error response from the token endpoint (RFC 6749 5.2).
+type oidcError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *oidcError) Error() string {
+	return "bedrock: OIDC token endpoint: " + e.Code
+}
+
+// exchange redeems an authorization code for an ID token.
+func (cfg OIDCConfig) exchange(ctx context.Context, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {cfg.RedirectURL},
+		"client_id":     {cfg.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.Provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(cfg.ClientID), url.QueryEscape(cfg.ClientSecret))
+	}
+
+	client := cfg.Provider.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("bedrock: OIDC token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		oidcError
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("bedrock: OIDC token endpoint: status %d: %w", resp.StatusCode, err)
+	}
+	if body.Code != "" {
+		return "", &body.oidcError
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return "", fmt.Errorf("bedrock: OIDC token endpoint: status %d without an ID token", resp.StatusCode)
+	}
+	return body.IDToken, nil
+}
+
+// verify validates an ID token for this client and login.
+func (cfg OIDCConfig) verify(ctx context.Context, idToken, nonce string) (OIDCClaims, error) {
+	claims, err := ValidateToken[OIDCClaims](ctx, idToken, TokenConfig{
+		Keys:           cfg.Provider.Keys,
+		Issuer:         cfg.Provider.Issuer,
+		Audience:       []string{cfg.ClientID},
+		RequiredClaims: []string{"sub", "exp"},
+	})
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return OIDCClaims{}, errors.New("nonce mismatch")
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != cfg.ClientID {
+		return OIDCClaims{}, errors.New("azp does not match the client ID")
+	}
+	return claims, nil
+}
+
+func (cfg OIDCConfig) scopes() []string {
+	if len(cfg.Scopes) == 0 {
+		return []string{"openid", "email", "profile"}
+	}
+	return cfg.Scopes
+}
+
+func (cfg OIDCConfig) cookieName() string {
+	if cfg.CookieName == "" {
+		return DefaultOIDCCookie
+	}
+	return cfg.CookieName
+}
+
+// cookie returns the login state cookie. SameSite=Lax lets it through on
+// the top-level redirect back from the provider.
+func (cfg OIDCConfig) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     cfg.cookieName(),
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   !cfg.Insecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// isLocalPath reports whether path is safe to redirect to: an absolute
+// path on this site rather than another host.
+func isLocalPath(path string) bool {
+	return strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") && !strings.HasPrefix(path, "/\\")
+}
+
+// redirect is a 302 response to url.
+type redirect string
+
+func (r redirect) Write(ctx context.Context, w http.ResponseWriter) error {
+	w.Header().Set("Location", string(r))
+	w.WriteHeader(http.StatusFound)
+	return nil
+}
@@ -0,0 +1,378 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockOIDCProvider is a minimal OpenID Connect provider. Its authorization
+// endpoint logs in subject without asking and redirects back with a code.
+type mockOIDCProvider struct {
+	*httptest.Server
+	keys     *KeySet
+	clientID string
+	secret   string
+	subject  string
+
+	mu    sync.Mutex
+	codes map[string]url.Values // code -> authorization request
+	// claims, if set, changes the ID token before it is signed
+	claims func(*OIDCClaims)
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	p := &mockOIDCProvider{
+		keys:     NewKeySet(),
+		clientID: "bedrock-app",
+		secret:   "s3cret/+",
+		subject:  "google-123",
+		codes:    make(map[string]url.Values),
+	}
+	if err := p.keys.AddSigningKey("k1", testSigners(t)["ES256"]); err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize?prompt=none",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.keys.JWKSHandler()(r.Context(), r).Write(r.Context(), w)
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		code := rand.Text()
+		p.mu.Lock()
+		p.codes[code] = query
+		p.mu.Unlock()
+		callback := query.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(w, r, callback, http.StatusFound)
+	})
+	mux.HandleFunc("/token", p.token)
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *mockOIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	fail := func(code string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+
+	user, pass, _ := r.BasicAuth()
+	user, _ = url.QueryUnescape(user)
+	pass, _ = url.QueryUnescape(pass)
+	if user != p.clientID || pass != p.secret {
+		fail("invalid_client")
+		return
+	}
+
+	p.mu.Lock()
+	auth, ok := p.codes[r.PostFormValue("code")]
+	delete(p.codes, r.PostFormValue("code")) // codes are single-use
+	p.mu.Unlock()
+	challenge := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || r.PostFormValue("grant_type") != "authorization_code" ||
+		r.PostFormValue("redirect_uri") != auth.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(challenge[:]) != auth.Get("code_challenge") {
+		fail("invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := OIDCClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.URL,
+			Subject:   p.subject,
+			Audience:  jwt.ClaimStrings{p.clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+		Nonce:         auth.Get("nonce"),
+		Email:         "ada@example.com",
+		EmailVerified: true,
+	}
+	if p.claims != nil {
+		p.claims(&claims)
+	}
+	idToken, _ := p.keys.Sign(claims)
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": idToken})
+}
+
+// oidcBrowser walks through a login like a browser: it starts at the login
+// handler, follows the provider's redirect and returns the callback's response.
+func oidcBrowser(t *testing.T, login, callback Handler, loginPath string, tamper func(*url.URL)) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	login(context.Background(), httptest.NewRequest("GET", loginPath, nil)).Write(context.Background(), w)
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected redirect to the provider, got %d", w.Code)
+	}
+	stateCookie := w.Result().Cookies()[0]
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	back, _ := url.Parse(resp.Header.Get("Location"))
+	if tamper != nil {
+		tamper(back)
+	}
+
+	req := httptest.NewRequest("GET", back.String(), nil)
+	req.AddCookie(stateCookie)
+	w = httptest.NewRecorder()
+	callback(context.Background(), req).Write(context.Background(), w)
+	return w
+}
+
+func testOIDCConfig(t *testing.T, p *mockOIDCProvider) OIDCConfig {
+	t.Helper()
+	provider, err := DiscoverOIDC(context.Background(), p.URL)
+	if err != nil {
+		t.Fatalf("DiscoverOIDC failed: %v", err)
+	}
+	return OIDCConfig{
+		Provider:     provider,
+		ClientID:     p.clientID,
+		ClientSecret: p.secret,
+		RedirectURL:  "https://app.example.com/auth/callback",
+		CookieKey:    bytes.Repeat([]byte{9}, 32),
+		MapUser: func(ctx context.Context, claims OIDCClaims) (string, error) {
+			if !claims.EmailVerified {
+				return "", Forbidden("email not verified")
+			}
+			return "user-" + claims.Subject, nil
+		},
+	}
+}
+
+func TestOIDC_LoginRedirect(t *testing.T) {
+	cfg := testOIDCConfig(t, newMockOIDCProvider(t))
+
+	w := httptest.NewRecorder()
+	cfg.LoginHandler()(context.Background(), httptest.NewRequest("GET", "/auth/login", nil)).Write(context.Background(), w)
+	location, _ := url.Parse(w.Header().Get("Location"))
+	query := location.Query()
+	for name, want := range map[string]string{
+		"prompt":                "none", // kept from the endpoint
+		"response_type":         "code",
+		"client_id":             "bedrock-app",
+		"scope":                 "openid email profile",
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(name); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+	if query.Get("state") == "" || query.Get("nonce") == "" || query.Get("code_challenge") == "" {
+		t.Errorf("expected state, nonce and PKCE challenge, got %v", query)
+	}
+
+	cookie := w.Result().Cookies()[0]
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode || cookie.MaxAge <= 0 {
+		t.Errorf("unexpected cookie attributes: %+v", cookie)
+	}
+	if strings.Contains(location.RawQuery, cookie.Value) {
+		t.Error("login state cookie leaked into the redirect")
+	}
+
+	// return_to only accepts paths on this site
+	aead, _ := cfg.aead()
+	for returnTo, want := range map[string]string{"/bookings?page=2": "/bookings?page=2", "//evil.test": "/", "https://evil.test": "/"} {
+		w := httptest.NewRecorder()
+		cfg.LoginHandler()(context.Background(), httptest.NewRequest("GET", "/auth/login?return_to="+url.QueryEscape(returnTo), nil)).Write(context.Background(), w)
+		req := httptest.NewRequest("GET", "/auth/callback", nil)
+		req.AddCookie(w.Result().Cookies()[0])
+		if login, _ := cfg.login(aead, req); login.ReturnTo != want {
+			t.Errorf("return_to %q: got %q, want %q", returnTo, login.ReturnTo, want)
+		}
+	}
+}
+
+func TestOIDC_SessionLogin(t *testing.T) {
+	cfg := testOIDCConfig(t, newMockOIDCProvider(t))
+	sessions := Sessions(SessionConfig{Store: NewMemorySessionStore()})
+	callback := Chain(cfg.CallbackHandler(), sessions)
+
+	w := oidcBrowser(t, cfg.LoginHandler(), callback, "/auth/login?return_to=/bookings", nil)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/bookings" {
+		t.Fatalf("expected redirect to /bookings, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	var session *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		switch c.Name {
+		case DefaultSessionCookie:
+			session = c
+		case DefaultOIDCCookie:
+			if c.MaxAge >= 0 {
+				t.Error("expected the login state cookie to be cleared")
+			}
+		}
+	}
+	if session == nil {
+		t.Fatal("expected a session cookie")
+	}
+
+	var userID string
+	me := Chain(func(ctx context.Context, r *http.Request) Response {
+		userID, _ = GetUserID(ctx)
+		return JSON(200, nil)
+	}, sessions)
+	req := httptest.NewRequest("GET", "/me", nil)
+	req.AddCookie(session)
+	me(context.Background(), req).Write(context.Background(), httptest.NewRecorder())
+	if userID != "user-google-123" {
+		t.Errorf("expected to be logged in as user-google-123, got %q", userID)
+	}
+
+	// Without Sessions the login would be lost
+	w = oidcBrowser(t, cfg.LoginHandler(), cfg.CallbackHandler(), "/auth/login", nil)
+	if w.Code != 500 {
+		t.Errorf("expected 500 without the Sessions middleware, got %d", w.Code)
+	}
+}
+
+func TestOIDC_TokenLogin(t *testing.T) {
+	cfg := testOIDCConfig(t, newMockOIDCProvider(t))
+	cfg.Tokens = &RefreshConfig{Store: NewMemoryTokenStore(), Secret: "test-secret"}
+
+	w := oidcBrowser(t, cfg.LoginHandler(), cfg.CallbackHandler(), "/auth/login", nil)
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var pair TokenPair
+	json.NewDecoder(w.Body).Decode(&pair)
+	if userID, err := ValidateJWT(pair.AccessToken, "test-secret"); err != nil || userID != "user-google-123" {
+		t.Errorf("expected an access token for user-google-123, got %q, %v", userID, err)
+	}
+}
+
+func TestOIDC_Rejections(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims func(*OIDCClaims)
+		tamper func(*url.URL)
+		want   int
+	}{
+		{"wrong state", nil, func(u *url.URL) {
+			q := u.Query()
+			q.Set("state", "forged")
+			u.RawQuery = q.Encode()
+		}, 400},
+		{"provider error", nil, func(u *url.URL) { u.RawQuery = "error=access_denied" }, 401},
+		{"wrong code", nil, func(u *url.URL) {
+			q := u.Query()
+			q.Set("code", "forged")
+			u.RawQuery = q.Encode()
+		}, 401},
+		{"wrong nonce", func(c *OIDCClaims) { c.Nonce = "replayed" }, nil, 401},
+		{"wrong audience", func(c *OIDCClaims) { c.Audience = jwt.ClaimStrings{"other-app"} }, nil, 401},
+		{"wrong issuer", func(c *OIDCClaims) { c.Issuer = "https://evil.test" }, nil, 401},
+		{"expired", func(c *OIDCClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) }, nil, 401},
+		{"foreign azp", func(c *OIDCClaims) {
+			c.Audience = jwt.ClaimStrings{"bedrock-app", "other-app"}
+			c.AuthorizedParty = "other-app"
+		}, nil, 401},
+		{"rejected by MapUser", func(c *OIDCClaims) { c.EmailVerified = false }, nil, 403},
+	}
+	for _, tt := range tests {
+		p := newMockOIDCProvider(t)
+		p.claims = tt.claims
+		cfg := testOIDCConfig(t, p)
+		cfg.Tokens = &RefreshConfig{Store: NewMemoryTokenStore(), Secret: "test-secret"}
+
+		w := oidcBrowser(t, cfg.LoginHandler(), cfg.CallbackHandler(), "/auth/login", tt.tamper)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+	}
+
+	// A callback without the login state cookie
+	cfg := testOIDCConfig(t, newMockOIDCProvider(t))
+	cfg.Tokens = &RefreshConfig{Store: NewMemoryTokenStore(), Secret: "test-secret"}
+	w := httptest.NewRecorder()
+	cfg.CallbackHandler()(context.Background(), httptest.NewRequest("GET", "/auth/callback?code=x&state=y", nil)).Write(context.Background(), w)
+	if w.Code != 400 {
+		t.Errorf("expected 400 without login state, got %d", w.Code)
+	}
+}
+
+func TestOIDC_LoginCookie(t *testing.T) {
+	cfg := testOIDCConfig(t, newMockOIDCProvider(t))
+	cfg.Tokens = &RefreshConfig{Store: NewMemoryTokenStore(), Secret: "test-secret"}
+
+	w := httptest.NewRecorder()
+	cfg.LoginHandler()(context.Background(), httptest.NewRequest("GET", "/auth/login", nil)).Write(context.Background(), w)
+	location, _ := url.Parse(w.Header().Get("Location"))
+	state := location.Query().Get("state")
+	cookie := w.Result().Cookies()[0]
+	if plain, _ := base64.RawURLEncoding.DecodeString(cookie.Value); bytes.Contains(plain, []byte(state)) {
+		t.Error("login state cookie is not encrypted")
+	}
+
+	callback := func(value string) int {
+		req := httptest.NewRequest("GET", "/auth/callback?code=x&state="+url.QueryEscape(state), nil)
+		req.AddCookie(&http.Cookie{Name: DefaultOIDCCookie, Value: value})
+		w := httptest.NewRecorder()
+		cfg.CallbackHandler()(context.Background(), req).Write(context.Background(), w)
+		return w.Code
+	}
+
+	// Altered, forged, foreign and expired cookies are rejected
+	forged, _ := json.Marshal(oidcLogin{State: state, Nonce: "n", Verifier: "v", ReturnTo: "/", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	foreign, _ := newGCM(bytes.Repeat([]byte{1}, 32))
+	fromOtherKey, _ := sealLogin(foreign, oidcLogin{State: state, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	aead, _ := cfg.aead()
+	expired, _ := sealLogin(aead, oidcLogin{State: state, ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	for name, value := range map[string]string{
+		"altered":        cookie.Value[:len(cookie.Value)-2] + "AA",
+		"unencrypted":    base64.RawURLEncoding.EncodeToString(forged),
+		"from other key": fromOtherKey,
+		"expired":        expired,
+	} {
+		if code := callback(value); code != 400 {
+			t.Errorf("%s: expected 400, got %d", name, code)
+		}
+	}
+
+	// A missing or short key is a configuration error
+	cfg.CookieKey = []byte("short")
+	w = httptest.NewRecorder()
+	cfg.LoginHandler()(context.Background(), httptest.NewRequest("GET", "/auth/login", nil)).Write(context.Background(), w)
+	if w.Code != 500 {
+		t.Errorf("expected 500 with a short key, got %d", w.Code)
+	}
+}
+
+func TestDiscoverOIDC_IssuerMismatch(t *testing.T) {
+	p := newMockOIDCProvider(t)
+	if _, err := DiscoverOIDC(context.Background(), p.URL+"/"); err == nil {
+		t.Error("expected error when the document's issuer differs")
+	}
+}
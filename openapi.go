@@ -0,0 +1,652 @@
+package bedrock
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// DefaultOpenAPIPath is where the OpenAPI document is served when OpenAPIConfig.Path is empty.
+const DefaultOpenAPIPath = "/openapi.json"
+
+// OpenAPIConfig describes the API for OpenAPI document generation.
+type OpenAPIConfig struct {
+	Title       string   // optional; defaults to "API"
+	Version     string   // optional; defaults to "0.0.0"
+	Description string   // optional
+	Servers     []string // optional; base URLs, e.g. "https://api.example.com"
+	Path        string   // optional; where RunContext serves the document, defaults to DefaultOpenAPIPath
+
+	// SecuritySchemes names the schemes Route.Security can refer to.
+	// Defaults to {"bearer": JWT bearer token}, matching RequireAuth.
+	SecuritySchemes map[string]SecurityScheme
+}
+
+// SecurityScheme is an OpenAPI security scheme object.
+type SecurityScheme struct {
+	Type         string `json:"type"`                   // "http", "apiKey", "oauth2" or "openIdConnect"
+	Scheme       string `json:"scheme,omitempty"`       // for "http", e.g. "bearer"
+	BearerFormat string `json:"bearerFormat,omitempty"` // for "http", e.g. "JWT"
+	Name         string `json:"name,omitempty"`         // for "apiKey", the header or query parameter name
+	In           string `json:"in,omitempty"`           // for "apiKey", "header" or "query"
+	OpenIDURL    string `json:"openIdConnectUrl,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// withDefaults returns a copy of c with every optional field filled in.
+func (c OpenAPIConfig) withDefaults() OpenAPIConfig {
+	if c.Title == "" {
+		c.Title = "API"
+	}
+	if c.Version == "" {
+		c.Version = "0.0.0"
+	}
+	if c.Path == "" {
+		c.Path = DefaultOpenAPIPath
+	}
+	if c.SecuritySchemes == nil {
+		c.SecuritySchemes = map[string]SecurityScheme{
+			"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+		}
+	}
+	return c
+}
+
+// GenerateOpenAPI builds an OpenAPI 3.1 JSON document from the routes.
+//
+// Each route becomes an operation. Summary, Tags and Security are copied
+// from the route. Route.Request is reflected the same way Bind reads it:
+// fields tagged path, query or header become parameters and the remaining
+// JSON fields become the request body. `validate` tags are mapped onto the
+// schema (required, min, max, len, email, oneof). Route.Response describes
+// the 200 response body. Named struct types are shared under
+// components/schemas.
+//
+// Prefix routes (IsPrefix) cannot be described and are left out.
+//
+// Example:
+//
+//	doc, err := bedrock.GenerateOpenAPI(app.Routes(), bedrock.OpenAPIConfig{
+//	    Title:   "Bookings API",
+//	    Version: "1.4.0",
+//	})
+func GenerateOpenAPI(routes []Route, cfg OpenAPIConfig) ([]byte, error) {
+	cfg = cfg.withDefaults()
+	g := &openAPIGenerator{
+		schemas: map[string]*schema{},
+		names:   map[reflect.Type]string{},
+	}
+
+	doc := openAPIDocument{
+		OpenAPI: "3.1.0",
+		Info: openAPIInfo{
+			Title:       cfg.Title,
+			Version:     cfg.Version,
+			Description: cfg.Description,
+		},
+		Paths: map[string]map[string]*openAPIOperation{},
+	}
+	for _, url := range cfg.Servers {
+		doc.Servers = append(doc.Servers, openAPIServer{URL: url})
+	}
+
+	for _, route := range routes {
+		if route.IsPrefix {
+			continue
+		}
+		path, pathParams := openAPIPath(route.Path)
+		op, err := g.operation(route, pathParams)
+		if err != nil {
+			return nil, fmt.Errorf("bedrock: openapi: %s %s: %w", route.Method, route.Path, err)
+		}
+		for _, name := range route.Security {
+			if _, ok := cfg.SecuritySchemes[name]; !ok {
+				return nil, fmt.Errorf("bedrock: openapi: %s %s: unknown security scheme %q", route.Method, route.Path, name)
+			}
+		}
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*openAPIOperation{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	doc.Components = openAPIComponents{
+		Schemas:         g.schemas,
+		SecuritySchemes: cfg.SecuritySchemes,
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// WriteOpenAPI generates the OpenAPI document for routes and writes it to filename.
+//
+// Example (e.g. from a go:generate command or a CI step):
+//
+//	app := myapp.New()
+//	if err := bedrock.WriteOpenAPI("openapi.json", app.Routes(), cfg); err != nil {
+//	    log.Fatal(err)
+//	}
+func WriteOpenAPI(filename string, routes []Route, cfg OpenAPIConfig) error {
+	doc, err := GenerateOpenAPI(routes, cfg)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(doc, '\n'), 0o644)
+}
+
+// registerOpenAPIEndpoint serves a pre-generated document on the router.
+func registerOpenAPIEndpoint(router *mux.Router, path string, doc []byte) {
+	router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	}).Methods("GET")
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Servers    []openAPIServer                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*schema        `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type openAPIOperation struct {
+	Summary     string                     `json:"summary,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *schema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// schema is the subset of JSON Schema used by the generator.
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+// errorSchemaName is the component describing HTTPError response bodies.
+// It is reserved, so app types never take or share it.
+const errorSchemaName = "BedrockError"
+
+// openAPIGenerator collects named schemas while describing routes.
+type openAPIGenerator struct {
+	schemas map[string]*schema      // components/schemas by name
+	names   map[reflect.Type]string // component name of each named struct type
+}
+
+// operation describes a single route.
+func (g *openAPIGenerator) operation(route Route, pathParams []string) (*openAPIOperation, error) {
+	op := &openAPIOperation{
+		Summary:   route.Summary,
+		Tags:      route.Tags,
+		Responses: map[string]openAPIResponse{},
+	}
+
+	declared := map[string]bool{}
+	if route.Request != nil {
+		t := derefType(reflect.TypeOf(route.Request))
+		if t.Kind() == reflect.Struct && !isLeafType(t) {
+			params, err := g.parameters(t)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range params {
+				if p.In == "path" {
+					declared[p.Name] = true
+				}
+			}
+			op.Parameters = params
+		}
+
+		if hasBody(t) && route.Method != http.MethodGet && route.Method != http.MethodHead {
+			s, err := g.schemaFor(t)
+			if err != nil {
+				return nil, err
+			}
+			op.RequestBody = &openAPIRequestBody{
+				Required: true,
+				Content:  map[string]openAPIMediaType{"application/json": {Schema: s}},
+			}
+		}
+
+		op.Responses["400"] = g.errorResponse("Invalid request")
+		op.Responses["422"] = g.errorResponse("Validation failed")
+	}
+
+	// Path variables not bound by the request type are still documented
+	for _, name := range pathParams {
+		if !declared[name] {
+			op.Parameters = append(op.Parameters, openAPIParameter{
+				Name: name, In: "path", Required: true, Schema: &schema{Type: "string"},
+			})
+		}
+	}
+
+	ok := openAPIResponse{Description: "OK"}
+	if route.Response != nil {
+		s, err := g.schemaFor(reflect.TypeOf(route.Response))
+		if err != nil {
+			return nil, err
+		}
+		ok.Content = map[string]openAPIMediaType{"application/json": {Schema: s}}
+	}
+	op.Responses["200"] = ok
+
+	for _, name := range route.Security {
+		op.Security = append(op.Security, map[string][]string{name: {}})
+	}
+	if len(route.Security) > 0 {
+		op.Responses["401"] = g.errorResponse("Unauthorized")
+	}
+
+	return op, nil
+}
+
+// errorResponse describes an HTTPError response, registering its schema on first use.
+func (g *openAPIGenerator) errorResponse(description string) openAPIResponse {
+	if _, ok := g.schemas[errorSchemaName]; !ok {
+		g.schemas[errorSchemaName] = &schema{
+			Type: "object",
+			Properties: map[string]*schema{
+				"error":      {Type: "string"},
+				"code":       {Type: "string"},
+				"details":    {},
+				"request_id": {Type: "string"},
+			},
+			Required: []string{"error"},
+		}
+	}
+	return openAPIResponse{
+		Description: description,
+		Content: map[string]openAPIMediaType{
+			"application/json": {Schema: &schema{Ref: "#/components/schemas/" + errorSchemaName}},
+		},
+	}
+}
+
+// parameters describes the path, query and header tagged fields of t.
+func (g *openAPIGenerator) parameters(t reflect.Type) ([]openAPIParameter, error) {
+	var params []openAPIParameter
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous && derefType(sf.Type).Kind() == reflect.Struct {
+			embedded, err := g.parameters(derefType(sf.Type))
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, embedded...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		in, name := paramSource(sf)
+		if in == "" {
+			continue
+		}
+		s, err := g.schemaFor(sf.Type)
+		if err != nil {
+			return nil, err
+		}
+		applyValidateTag(s, sf)
+		params = append(params, openAPIParameter{
+			Name:     name,
+			In:       in,
+			Required: in == "path" || hasRule(sf, "required"),
+			Schema:   s,
+		})
+	}
+	return params, nil
+}
+
+// schemaFor describes t, registering named structs under components/schemas.
+func (g *openAPIGenerator) schemaFor(t reflect.Type) (*schema, error) {
+	t = derefType(t)
+
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return &schema{Type: "string", Format: "date-time"}, nil
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return &schema{}, nil // custom JSON encoding, shape unknown
+	case isLeafType(t):
+		return &schema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &schema{Type: "string"}, nil
+	case reflect.Bool:
+		return &schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s := &schema{Type: "integer"}
+		if t.Bits() == 64 || t.Kind() == reflect.Int || t.Kind() == reflect.Uint {
+			s.Format = "int64"
+		} else {
+			s.Format = "int32"
+		}
+		return s, nil
+	case reflect.Float32:
+		return &schema{Type: "number", Format: "float"}, nil
+	case reflect.Float64:
+		return &schema{Type: "number", Format: "double"}, nil
+	case reflect.Interface:
+		return &schema{}, nil
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &schema{Type: "string", Format: "byte"}, nil // encoding/json uses base64
+		}
+		items, err := g.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Type: "array", Items: items}, nil
+
+	case reflect.Map:
+		values, err := g.schemaFor(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &schema{Type: "object", AdditionalProperties: values}, nil
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.componentName(t)
+			g.names[t] = name
+			g.schemas[name] = &schema{} // placeholder, allows recursive types
+			s, err := g.structSchema(t)
+			if err != nil {
+				return nil, err
+			}
+			g.schemas[name] = s
+		}
+		return &schema{Ref: "#/components/schemas/" + name}, nil
+	}
+
+	return nil, fmt.Errorf("cannot describe type %s", t)
+}
+
+// structSchema describes the JSON fields of a struct, following encoding/json naming.
+// Fields bound from the path, query or headers are left out.
+func (g *openAPIGenerator) structSchema(t reflect.Type) (*schema, error) {
+	s := &schema{Type: "object", Properties: map[string]*schema{}}
+	if err := g.addFields(s, t); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (g *openAPIGenerator) addFields(s *schema, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, embedded := jsonFieldName(sf)
+		if embedded {
+			if err := g.addFields(s, derefType(sf.Type)); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			continue
+		}
+		if in, _ := paramSource(sf); in != "" {
+			continue
+		}
+
+		fs, err := g.schemaFor(sf.Type)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		applyValidateTag(fs, sf)
+		s.Properties[name] = fs
+		if hasRule(sf, "required") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return nil
+}
+
+// componentName returns a unique, URL-safe component name for t.
+func (g *openAPIGenerator) componentName(t reflect.Type) string {
+	base := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, t.Name())
+
+	name := base
+	for i := 2; ; i++ {
+		if _, taken := g.schemas[name]; !taken && name != errorSchemaName {
+			return name
+		}
+		name = base + strconv.Itoa(i)
+	}
+}
+
+// applyValidateTag maps the `validate` rules of sf onto s.
+func applyValidateTag(s *schema, sf reflect.StructField) {
+	tag := sf.Tag.Get("validate")
+	if tag == "" || s.Ref != "" {
+		return
+	}
+	kind := derefType(sf.Type).Kind()
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch name {
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			applyBound(s, kind, name, n)
+		case "email":
+			s.Format = "email"
+		case "oneof":
+			for _, option := range strings.Fields(param) {
+				if s.Type == "integer" || s.Type == "number" {
+					if n, err := strconv.ParseFloat(option, 64); err == nil {
+						s.Enum = append(s.Enum, n)
+						continue
+					}
+				}
+				s.Enum = append(s.Enum, option)
+			}
+		}
+	}
+}
+
+// applyBound sets the length, item count or numeric limit matching a min, max or len rule.
+func applyBound(s *schema, kind reflect.Kind, rule string, n float64) {
+	count := int(n)
+	switch {
+	case s.Type == "string":
+		if rule != "max" {
+			s.MinLength = &count
+		}
+		if rule != "min" {
+			s.MaxLength = &count
+		}
+	case s.Type == "array":
+		if rule != "max" {
+			s.MinItems = &count
+		}
+		if rule != "min" {
+			s.MaxItems = &count
+		}
+	case s.Type == "integer" || s.Type == "number":
+		if rule == "min" {
+			s.Minimum = &n
+		} else if rule == "max" {
+			s.Maximum = &n
+		}
+	}
+}
+
+// openAPIPath converts a mux path template to OpenAPI form and lists its variables:
+// "/users/{id:[0-9]+}" becomes "/users/{id}".
+func openAPIPath(path string) (string, []string) {
+	var b strings.Builder
+	var names []string
+	for i := 0; i < len(path); i++ {
+		if path[i] != '{' {
+			b.WriteByte(path[i])
+			continue
+		}
+		// Find the matching brace; patterns may contain braces, e.g. {id:[0-9]{4}}
+		depth, end := 0, i
+		for ; end < len(path); end++ {
+			if path[end] == '{' {
+				depth++
+			} else if path[end] == '}' {
+				depth--
+				if depth == 0 {
+					break
+				}
+			}
+		}
+		name, _, _ := strings.Cut(path[i+1:end], ":")
+		names = append(names, name)
+		b.WriteString("{" + name + "}")
+		i = end
+	}
+	return b.String(), names
+}
+
+// paramSource returns where Bind reads sf from, or "" for body fields.
+func paramSource(sf reflect.StructField) (in, name string) {
+	for _, source := range []string{"path", "query", "header"} {
+		if name := sf.Tag.Get(source); name != "" {
+			return source, name
+		}
+	}
+	return "", ""
+}
+
+// jsonFieldName returns the JSON name of sf, or "" if encoding/json skips it.
+// embedded reports an untagged embedded struct, whose fields are promoted.
+func jsonFieldName(sf reflect.StructField) (name string, embedded bool) {
+	tag := sf.Tag.Get("json")
+	tagName, _, _ := strings.Cut(tag, ",")
+	if tagName == "-" && tag == "-" {
+		return "", false
+	}
+	if sf.Anonymous && tagName == "" && derefType(sf.Type).Kind() == reflect.Struct {
+		return "", true
+	}
+	if !sf.IsExported() {
+		return "", false
+	}
+	if tagName != "" {
+		return tagName, false
+	}
+	return sf.Name, false
+}
+
+// hasBody reports whether t has any fields decoded from the JSON body.
+func hasBody(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || isLeafType(t) {
+		return true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, embedded := jsonFieldName(sf)
+		if embedded && hasBody(derefType(sf.Type)) {
+			return true
+		}
+		if in, _ := paramSource(sf); name != "" && in == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRule reports whether the `validate` tag of sf contains rule.
+func hasRule(sf reflect.StructField, rule string) bool {
+	for _, r := range strings.Split(sf.Tag.Get("validate"), ",") {
+		if name, _, _ := strings.Cut(strings.TrimSpace(r), "="); name == rule {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// isLeafType reports whether t is encoded as a JSON string via encoding.TextMarshaler.
+func isLeafType(t reflect.Type) bool {
+	return t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
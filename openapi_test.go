@@ -0,0 +1,288 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Jack4Code/bedrock/config"
+)
+
+type bookingRequest struct {
+	RoomID string    `path:"roomID" json:"-"`
+	Notify bool      `query:"notify" json:"-"`
+	Tenant string    `header:"X-Tenant" json:"-" validate:"required"`
+	Guests int       `json:"guests" validate:"required,min=1,max=8"`
+	Start  time.Time `json:"start"`
+	Plan   string    `json:"plan,omitempty" validate:"oneof=basic deluxe"`
+	Email  string    `json:"email" validate:"email"`
+	Notes  []string  `json:"notes" validate:"max=3"`
+}
+
+type apiBooking struct {
+	ID     string      `json:"id"`
+	Guests int         `json:"guests"`
+	Next   *apiBooking `json:"next,omitempty"`
+	secret string
+}
+
+func openAPITestRoutes() []Route {
+	noop := func(ctx context.Context, r *http.Request) Response { return JSON(200, nil) }
+	return []Route{
+		{
+			Method:   "POST",
+			Path:     "/rooms/{roomID}/bookings",
+			Handler:  noop,
+			Summary:  "Create a booking",
+			Tags:     []string{"bookings"},
+			Request:  bookingRequest{},
+			Response: apiBooking{},
+			Security: []string{"bearer"},
+		},
+		{
+			Method:   "GET",
+			Path:     "/bookings/{id:[0-9]{4}}",
+			Handler:  noop,
+			Response: []apiBooking{},
+		},
+		{Method: "GET", Path: "/static/", Handler: noop, IsPrefix: true},
+	}
+}
+
+// generateOpenAPI returns the generated document decoded as generic JSON.
+func generateOpenAPI(t *testing.T, routes []Route, cfg OpenAPIConfig) map[string]any {
+	t.Helper()
+	raw, err := GenerateOpenAPI(routes, cfg)
+	if err != nil {
+		t.Fatalf("GenerateOpenAPI failed: %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	return doc
+}
+
+// lookup follows keys through decoded JSON.
+func lookup(t *testing.T, v any, keys ...string) any {
+	t.Helper()
+	for _, key := range keys {
+		m, ok := v.(map[string]any)
+		if !ok {
+			t.Fatalf("%v: parent of %q is not an object", keys, key)
+		}
+		v = m[key]
+	}
+	return v
+}
+
+func TestGenerateOpenAPI_Operation(t *testing.T) {
+	doc := generateOpenAPI(t, openAPITestRoutes(), OpenAPIConfig{Title: "Bookings", Version: "1.0.0"})
+
+	if doc["openapi"] != "3.1.0" || lookup(t, doc, "info", "title") != "Bookings" {
+		t.Errorf("unexpected header: %v %v", doc["openapi"], doc["info"])
+	}
+
+	op := lookup(t, doc, "paths", "/rooms/{roomID}/bookings", "post")
+	if lookup(t, op, "summary") != "Create a booking" {
+		t.Errorf("summary not copied: %v", op)
+	}
+	if tags := lookup(t, op, "tags"); !reflect.DeepEqual(tags, []any{"bookings"}) {
+		t.Errorf("tags: got %v", tags)
+	}
+	if sec := lookup(t, op, "security"); !reflect.DeepEqual(sec, []any{map[string]any{"bearer": []any{}}}) {
+		t.Errorf("security: got %v", sec)
+	}
+
+	params := map[string]map[string]any{}
+	for _, p := range lookup(t, op, "parameters").([]any) {
+		param := p.(map[string]any)
+		params[param["in"].(string)+"."+param["name"].(string)] = param
+	}
+	if p := params["path.roomID"]; p == nil || p["required"] != true {
+		t.Errorf("path parameter missing or optional: %v", params)
+	}
+	if p := params["query.notify"]; p == nil || lookup(t, p, "schema", "type") != "boolean" || p["required"] != nil {
+		t.Errorf("query parameter wrong: %v", p)
+	}
+	if p := params["header.X-Tenant"]; p == nil || p["required"] != true {
+		t.Errorf("required header parameter wrong: %v", p)
+	}
+
+	for _, status := range []string{"200", "400", "401", "422"} {
+		if lookup(t, op, "responses", status) == nil {
+			t.Errorf("missing %s response", status)
+		}
+	}
+	if ref := lookup(t, op, "responses", "200", "content", "application/json", "schema", "$ref"); ref != "#/components/schemas/apiBooking" {
+		t.Errorf("200 response schema: got %v", ref)
+	}
+}
+
+func TestGenerateOpenAPI_Schemas(t *testing.T) {
+	doc := generateOpenAPI(t, openAPITestRoutes(), OpenAPIConfig{})
+	schemas := lookup(t, doc, "components", "schemas").(map[string]any)
+
+	body := schemas["bookingRequest"].(map[string]any)
+	props := body["properties"].(map[string]any)
+	for _, hidden := range []string{"RoomID", "roomID", "Notify", "Tenant"} {
+		if _, ok := props[hidden]; ok {
+			t.Errorf("bound field %s should not be in the body schema", hidden)
+		}
+	}
+	if !reflect.DeepEqual(body["required"], []any{"guests"}) {
+		t.Errorf("required: got %v", body["required"])
+	}
+	checks := map[string]any{
+		"guests/type":      "integer",
+		"guests/minimum":   1.0,
+		"guests/maximum":   8.0,
+		"start/type":       "string",
+		"start/format":     "date-time",
+		"email/format":     "email",
+		"notes/type":       "array",
+		"notes/items/type": "string",
+		"notes/maxItems":   3.0,
+	}
+	for path, want := range checks {
+		if got := lookup(t, props, strings.Split(path, "/")...); got != want {
+			t.Errorf("%s: got %v, want %v", path, got, want)
+		}
+	}
+	if enum := lookup(t, props, "plan", "enum"); !reflect.DeepEqual(enum, []any{"basic", "deluxe"}) {
+		t.Errorf("plan enum: got %v", enum)
+	}
+
+	// Recursive types refer to their own component
+	bookingProps := lookup(t, schemas, "apiBooking", "properties").(map[string]any)
+	if ref := lookup(t, bookingProps, "next", "$ref"); ref != "#/components/schemas/apiBooking" {
+		t.Errorf("recursive ref: got %v", ref)
+	}
+	if _, ok := bookingProps["secret"]; ok {
+		t.Error("unexported fields should be skipped")
+	}
+	if schemas["BedrockError"] == nil {
+		t.Error("error responses should reference the BedrockError schema")
+	}
+	if lookup(t, doc, "components", "securitySchemes", "bearer", "scheme") != "bearer" {
+		t.Error("default bearer security scheme missing")
+	}
+}
+
+func TestGenerateOpenAPI_ErrorSchemaName(t *testing.T) {
+	type Error struct {
+		Reason string `json:"reason"`
+	}
+	type BedrockError struct {
+		Cause string `json:"cause"`
+	}
+	noop := func(ctx context.Context, r *http.Request) Response { return JSON(200, nil) }
+	doc := generateOpenAPI(t, []Route{
+		{Method: "GET", Path: "/errors", Handler: noop, Response: Error{}},
+		{Method: "GET", Path: "/other", Handler: noop, Response: BedrockError{}},
+		{Method: "POST", Path: "/bookings", Handler: noop, Request: bookingRequest{}},
+	}, OpenAPIConfig{})
+
+	schemas := lookup(t, doc, "components", "schemas").(map[string]any)
+	if lookup(t, schemas, "Error", "properties", "reason") == nil {
+		t.Errorf("expected the app's Error type to keep its name, got %v", schemas["Error"])
+	}
+	if lookup(t, schemas, "BedrockError2", "properties", "cause") == nil {
+		t.Errorf("expected the app's BedrockError type to be renamed, got %v", schemas)
+	}
+	if lookup(t, schemas, "BedrockError", "properties", "request_id") == nil {
+		t.Errorf("expected bedrock's error schema, got %v", schemas["BedrockError"])
+	}
+	ref := lookup(t, doc, "paths", "/bookings", "post", "responses", "400", "content", "application/json", "schema", "$ref")
+	if ref != "#/components/schemas/BedrockError" {
+		t.Errorf("400 response schema: got %v", ref)
+	}
+}
+
+func TestGenerateOpenAPI_Paths(t *testing.T) {
+	doc := generateOpenAPI(t, openAPITestRoutes(), OpenAPIConfig{})
+	paths := doc["paths"].(map[string]any)
+
+	op, ok := paths["/bookings/{id}"].(map[string]any)
+	if !ok {
+		t.Fatalf("mux pattern not stripped from path: %v", paths)
+	}
+	params := lookup(t, op, "get", "parameters").([]any)
+	if len(params) != 1 || params[0].(map[string]any)["name"] != "id" {
+		t.Errorf("undeclared path variable should be documented: %v", params)
+	}
+	if _, ok := lookup(t, op, "get").(map[string]any)["requestBody"]; ok {
+		t.Error("GET without request type should have no body")
+	}
+	if _, ok := paths["/static/"]; ok {
+		t.Error("prefix routes should be left out")
+	}
+}
+
+func TestGenerateOpenAPI_UnknownSecurityScheme(t *testing.T) {
+	routes := []Route{{Method: "GET", Path: "/", Handler: helloRoute().Handler, Security: []string{"apiKey"}}}
+	_, err := GenerateOpenAPI(routes, OpenAPIConfig{})
+	if err == nil || !strings.Contains(err.Error(), `unknown security scheme "apiKey"`) {
+		t.Errorf("expected unknown scheme error, got %v", err)
+	}
+}
+
+func TestWriteOpenAPI(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "openapi.json")
+	if err := WriteOpenAPI(filename, openAPITestRoutes(), OpenAPIConfig{}); err != nil {
+		t.Fatalf("WriteOpenAPI failed: %v", err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil || !json.Valid(data) {
+		t.Errorf("expected a valid JSON file, got %v", err)
+	}
+}
+
+func TestRunContext_ServesOpenAPI(t *testing.T) {
+	port := freePort(t)
+	app := &testApp{routes: []Route{helloRoute()}}
+
+	opts := quietOptions()
+	opts.OpenAPI = &OpenAPIConfig{Title: "Hello", Path: "/docs/openapi.json"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := runInBackground(ctx, app, config.BaseConfig{HTTPPort: port, HealthPort: port}, opts)
+	defer func() {
+		cancel()
+		waitForResult(t, done)
+	}()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/docs/openapi.json", port)
+	waitForStatus(t, url, 200)
+
+	resp, err := testClient.Get(url)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `"/hello"`) {
+		t.Errorf("served document missing /hello: %s", body)
+	}
+}
+
+func TestRunContext_OpenAPIRouteConflict(t *testing.T) {
+	port := freePort(t)
+	app := &testApp{routes: []Route{{Method: "GET", Path: DefaultOpenAPIPath, Handler: helloRoute().Handler}}}
+
+	opts := quietOptions()
+	opts.OpenAPI = &OpenAPIConfig{}
+
+	err := RunContext(context.Background(), app, config.BaseConfig{HTTPPort: port, HealthPort: port}, opts)
+	if err == nil || !strings.Contains(err.Error(), "route conflict") {
+		t.Fatalf("expected route conflict error, got %v", err)
+	}
+}
@@ -0,0 +1,367 @@
+package bedrock
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Defaults used by ParseListParams when the ListConfig fields are zero.
+const (
+	DefaultListLimit    = 20
+	DefaultListMaxLimit = 100
+)
+
+// ListConfig describes what a list endpoint accepts.
+type ListConfig struct {
+	DefaultLimit int // optional; defaults to DefaultListLimit
+	MaxLimit     int // optional; defaults to DefaultListMaxLimit, larger limits are rejected
+
+	// SortFields lists the fields clients may sort by. Sorting is rejected if empty.
+	SortFields []string
+	// DefaultSort is used when the request has no sort parameter, e.g. "-created_at".
+	DefaultSort string
+
+	// Filters maps each filterable field to its allowed operators
+	// (eq, ne, gt, gte, lt, lte, in, like), e.g.
+	// {"status": {"eq", "in"}, "created_at": {"gte", "lte"}}.
+	Filters map[string][]string
+
+	// CursorSecret signs cursors created with EncodeCursor. Cursor
+	// pagination is disabled if empty.
+	CursorSecret []byte
+}
+
+// ListParams is a parsed list request.
+type ListParams struct {
+	Page    int         // 1-based page number; 1 when paginating by cursor
+	Limit   int         // page size
+	Sort    []SortField // in priority order
+	Filters []Filter    // sorted by field, then operator
+
+	cursor []byte // verified cursor payload, nil if none
+}
+
+// SortField is one field of a sort parameter.
+type SortField struct {
+	Field string
+	Desc  bool // set by a leading "-", e.g. sort=-created_at
+}
+
+// Filter is one filter parameter, e.g. filter[created_at][gte]=2024-01-01.
+type Filter struct {
+	Field string
+	Op    string // "eq" for filter[field]=value
+	Value string // raw value; comma-separated for "in"
+}
+
+// Offset returns the number of items before the current page.
+func (p ListParams) Offset() int {
+	return (p.Page - 1) * p.Limit
+}
+
+// HasCursor reports whether the request carried a valid cursor.
+func (p ListParams) HasCursor() bool {
+	return p.cursor != nil
+}
+
+// DecodeCursor decodes the request's cursor into v, which must be a pointer
+// to the type passed to EncodeCursor. It returns an error if there is no cursor.
+func (p ListParams) DecodeCursor(v any) error {
+	if p.cursor == nil {
+		return errors.New("bedrock: no cursor")
+	}
+	return json.Unmarshal(p.cursor, v)
+}
+
+// listOperators are the filter operators ListConfig.Filters may allow.
+var listOperators = []string{"eq", "ne", "gt", "gte", "lt", "lte", "in", "like"}
+
+// ParseListParams parses page, limit, sort, filter and cursor query
+// parameters according to cfg:
+//
+//	?page=2&limit=50
+//	?sort=-created_at,name
+//	?filter[status]=active&filter[created_at][gte]=2024-01-01
+//	?cursor=eyJpZCI6NDJ9.3q2-7w
+//
+// Disallowed or malformed values return a *ParamError, which FromError
+// converts into a 400.
+//
+// Example:
+//
+//	var bookingList = bedrock.ListConfig{
+//	    SortFields:   []string{"created_at", "start"},
+//	    DefaultSort:  "-created_at",
+//	    Filters:      map[string][]string{"status": {"eq", "in"}},
+//	    CursorSecret: cursorSecret,
+//	}
+//
+//	params, err := bedrock.ParseListParams(r, bookingList)
+//	if err != nil {
+//	    return bedrock.FromError(err)
+//	}
+func ParseListParams(r *http.Request, cfg ListConfig) (ListParams, error) {
+	defaultLimit, maxLimit := cfg.DefaultLimit, cfg.MaxLimit
+	if maxLimit == 0 {
+		maxLimit = DefaultListMaxLimit
+	}
+	if defaultLimit == 0 {
+		defaultLimit = min(DefaultListLimit, maxLimit)
+	}
+
+	var p ListParams
+	var err error
+
+	if p.Page, err = QueryInt(r, "page", 1); err != nil {
+		return ListParams{}, err
+	}
+	if p.Page < 1 {
+		return ListParams{}, listParamError("page", strconv.Itoa(p.Page), "must be at least 1")
+	}
+
+	if p.Limit, err = QueryInt(r, "limit", defaultLimit); err != nil {
+		return ListParams{}, err
+	}
+	if p.Limit < 1 || p.Limit > maxLimit {
+		return ListParams{}, listParamError("limit", strconv.Itoa(p.Limit), fmt.Sprintf("must be between 1 and %d", maxLimit))
+	}
+	// Offset must not overflow
+	if p.Page-1 > math.MaxInt/p.Limit {
+		return ListParams{}, listParamError("page", strconv.Itoa(p.Page), "is too large")
+	}
+
+	sortParam := QueryParam(r, "sort")
+	if sortParam == "" {
+		sortParam = cfg.DefaultSort
+	}
+	if p.Sort, err = parseSort(sortParam, cfg.SortFields); err != nil {
+		return ListParams{}, err
+	}
+
+	if p.Filters, err = parseFilters(r.URL.Query(), cfg.Filters); err != nil {
+		return ListParams{}, err
+	}
+
+	if cursor := QueryParam(r, "cursor"); cursor != "" {
+		if len(cfg.CursorSecret) == 0 {
+			return ListParams{}, listParamError("cursor", cursor, "is not supported")
+		}
+		if p.cursor = verifyCursor(cursor, cfg.CursorSecret); p.cursor == nil {
+			return ListParams{}, listParamError("cursor", cursor, "is invalid")
+		}
+		p.Page = 1 // the cursor replaces the page
+	}
+
+	return p, nil
+}
+
+func listParamError(name, value, message string) *ParamError {
+	return &ParamError{Source: "query", Name: name, Value: value, Message: message}
+}
+
+// parseSort parses "-created_at,name", allowing only the given fields.
+func parseSort(value string, allowed []string) ([]SortField, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var fields []SortField
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		field := SortField{Field: strings.TrimPrefix(item, "-"), Desc: strings.HasPrefix(item, "-")}
+		if !slices.Contains(allowed, field.Field) {
+			return nil, listParamError("sort", value, fmt.Sprintf("cannot sort by %q", field.Field))
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// parseFilters collects filter[field] and filter[field][op] parameters.
+func parseFilters(query url.Values, allowed map[string][]string) ([]Filter, error) {
+	var filters []Filter
+	for key, values := range query {
+		rest, ok := strings.CutPrefix(key, "filter[")
+		if !ok {
+			continue
+		}
+		field, rest, ok := strings.Cut(rest, "]")
+		op := "eq"
+		if ok && rest != "" {
+			inner, open := strings.CutPrefix(rest, "[")
+			op, ok = strings.CutSuffix(inner, "]")
+			ok = ok && open
+		}
+		if !ok || field == "" || op == "" {
+			return nil, listParamError(key, values[0], "is not a valid filter, use filter[field] or filter[field][op]")
+		}
+
+		ops, filterable := allowed[field]
+		if !filterable {
+			return nil, listParamError(key, values[0], fmt.Sprintf("cannot filter by %q", field))
+		}
+		if !slices.Contains(ops, op) || !slices.Contains(listOperators, op) {
+			return nil, listParamError(key, values[0], fmt.Sprintf("operator %q is not allowed for %q", op, field))
+		}
+
+		for _, value := range values {
+			filters = append(filters, Filter{Field: field, Op: op, Value: value})
+		}
+	}
+
+	// Query values are a map; sort for a stable order
+	sort.SliceStable(filters, func(i, j int) bool {
+		if filters[i].Field != filters[j].Field {
+			return filters[i].Field < filters[j].Field
+		}
+		return filters[i].Op < filters[j].Op
+	})
+	return filters, nil
+}
+
+// EncodeCursor returns an opaque cursor for v, signed with cfg.CursorSecret.
+// v is typically the sort key of the last item on the page. Cursors are
+// signed but not encrypted: clients cannot forge or alter them, but should
+// not be given anything secret in them.
+//
+// Example:
+//
+//	type bookingCursor struct {
+//	    CreatedAt time.Time `json:"c"`
+//	    ID        int64     `json:"i"`
+//	}
+//
+//	last := bookings[len(bookings)-1]
+//	next, err := bookingList.EncodeCursor(bookingCursor{last.CreatedAt, last.ID})
+func (cfg ListConfig) EncodeCursor(v any) (string, error) {
+	if len(cfg.CursorSecret) == 0 {
+		return "", errors.New("bedrock: ListConfig.CursorSecret is required to encode cursors")
+	}
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(signCursor(payload, cfg.CursorSecret)), nil
+}
+
+// verifyCursor returns the payload of a cursor signed with secret, or nil.
+func verifyCursor(cursor string, secret []byte) []byte {
+	encodedPayload, encodedSig, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil || !hmac.Equal(sig, signCursor(payload, secret)) {
+		return nil
+	}
+	return payload
+}
+
+func signCursor(payload, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("bedrock-cursor:"))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// Paginated is a Response for one page of a list. It writes
+//
+//	{"items": [...], "next_cursor": "..." or null}
+//
+// with status 200 and an RFC 5988 Link header with "next", "prev" and
+// "first" relations built from the request URL, keeping its other query
+// parameters.
+//
+// With cursor pagination, set NextCursor; the "next" link carries it.
+// With page pagination, set HasMore when another page exists; the links
+// carry page numbers.
+//
+// Example:
+//
+//	return bedrock.Paginated[Booking]{
+//	    Request:    r,
+//	    Params:     params,
+//	    Items:      bookings,
+//	    NextCursor: next,
+//	}
+type Paginated[T any] struct {
+	Request    *http.Request // the list request, used to build links
+	Params     ListParams    // the parsed request parameters
+	Items      []T
+	NextCursor string // cursor for the next page; empty on the last page
+	HasMore    bool   // for page pagination, whether a next page exists
+}
+
+func (p Paginated[T]) Write(ctx context.Context, w http.ResponseWriter) error {
+	items := p.Items
+	if items == nil {
+		items = []T{}
+	}
+	var next *string
+	if p.NextCursor != "" {
+		next = &p.NextCursor
+	}
+
+	if p.Request != nil {
+		if links := p.links(); len(links) > 0 {
+			w.Header().Set("Link", strings.Join(links, ", "))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	return json.NewEncoder(w).Encode(struct {
+		Items      []T     `json:"items"`
+		NextCursor *string `json:"next_cursor"`
+	}{items, next})
+}
+
+// links returns the Link header values for p.
+func (p Paginated[T]) links() []string {
+	link := func(rel string, set map[string]string) string {
+		u := *p.Request.URL
+		query := u.Query()
+		for key, value := range set {
+			if value == "" {
+				query.Del(key)
+			} else {
+				query.Set(key, value)
+			}
+		}
+		u.RawQuery = query.Encode()
+		return fmt.Sprintf("<%s>; rel=%q", u.RequestURI(), rel)
+	}
+
+	var links []string
+	switch {
+	case p.NextCursor != "":
+		links = append(links, link("next", map[string]string{"cursor": p.NextCursor, "page": ""}))
+	case p.HasMore && !p.Params.HasCursor():
+		links = append(links, link("next", map[string]string{"page": strconv.Itoa(p.Params.Page + 1)}))
+	}
+	if p.Params.HasCursor() {
+		links = append(links, link("first", map[string]string{"cursor": "", "page": ""}))
+	} else if p.Params.Page > 1 {
+		links = append(links,
+			link("prev", map[string]string{"page": strconv.Itoa(p.Params.Page - 1)}),
+			link("first", map[string]string{"page": ""}),
+		)
+	}
+	return links
+}
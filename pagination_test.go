@@ -0,0 +1,189 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testListConfig = ListConfig{
+	MaxLimit:     50,
+	SortFields:   []string{"created_at", "name"},
+	DefaultSort:  "-created_at",
+	Filters:      map[string][]string{"status": {"eq", "in"}, "created_at": {"gte", "lte"}},
+	CursorSecret: []byte("cursor-secret"),
+}
+
+func TestParseListParams_Defaults(t *testing.T) {
+	p, err := ParseListParams(httptest.NewRequest("GET", "/bookings", nil), testListConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Page != 1 || p.Limit != DefaultListLimit || p.Offset() != 0 {
+		t.Errorf("unexpected paging: %+v", p)
+	}
+	if !reflect.DeepEqual(p.Sort, []SortField{{Field: "created_at", Desc: true}}) {
+		t.Errorf("default sort: got %+v", p.Sort)
+	}
+	if p.Filters != nil || p.HasCursor() {
+		t.Errorf("expected no filters or cursor: %+v", p)
+	}
+}
+
+func TestParseListParams(t *testing.T) {
+	r := httptest.NewRequest("GET", "/bookings?page=3&limit=10&sort=name,-created_at"+
+		"&filter[status][in]=open,pending&filter[created_at][gte]=2024-01-01&filter[created_at][lte]=2024-12-31", nil)
+
+	p, err := ParseListParams(r, testListConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Page != 3 || p.Limit != 10 || p.Offset() != 20 {
+		t.Errorf("unexpected paging: %+v", p)
+	}
+	wantSort := []SortField{{Field: "name"}, {Field: "created_at", Desc: true}}
+	if !reflect.DeepEqual(p.Sort, wantSort) {
+		t.Errorf("sort: got %+v", p.Sort)
+	}
+	wantFilters := []Filter{
+		{Field: "created_at", Op: "gte", Value: "2024-01-01"},
+		{Field: "created_at", Op: "lte", Value: "2024-12-31"},
+		{Field: "status", Op: "in", Value: "open,pending"},
+	}
+	if !reflect.DeepEqual(p.Filters, wantFilters) {
+		t.Errorf("filters: got %+v", p.Filters)
+	}
+}
+
+func TestParseListParams_Rejects(t *testing.T) {
+	tests := []struct {
+		query   string
+		name    string
+		message string
+	}{
+		{"limit=51", "limit", "must be between 1 and 50"},
+		{"limit=0", "limit", "must be between 1 and 50"},
+		{"page=0", "page", "must be at least 1"},
+		{"page=two", "page", "must be an integer"},
+		{"page=9223372036854775807&limit=2", "page", "is too large"},
+		{"sort=-password", "sort", `cannot sort by "password"`},
+		{"filter[owner]=me", "filter[owner]", `cannot filter by "owner"`},
+		{"filter[status][like]=op%25", "filter[status][like]", `operator "like" is not allowed for "status"`},
+		{"filter[status]x=1", "filter[status]x", "is not a valid filter, use filter[field] or filter[field][op]"},
+		{"cursor=forged.c2ln", "cursor", "is invalid"},
+	}
+	for _, tt := range tests {
+		_, err := ParseListParams(httptest.NewRequest("GET", "/?"+tt.query, nil), testListConfig)
+		var paramErr *ParamError
+		if !errors.As(err, &paramErr) {
+			t.Errorf("%s: expected *ParamError, got %v", tt.query, err)
+			continue
+		}
+		if paramErr.Name != tt.name || paramErr.Message != tt.message {
+			t.Errorf("%s: got %s %q", tt.query, paramErr.Name, paramErr.Message)
+		}
+	}
+}
+
+type testCursor struct {
+	ID int `json:"id"`
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor, err := testListConfig.EncodeCursor(testCursor{ID: 42})
+	if err != nil {
+		t.Fatalf("EncodeCursor failed: %v", err)
+	}
+
+	p, err := ParseListParams(httptest.NewRequest("GET", "/?page=4&cursor="+cursor, nil), testListConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded testCursor
+	if !p.HasCursor() || p.DecodeCursor(&decoded) != nil || decoded.ID != 42 {
+		t.Errorf("cursor not decoded: %+v", decoded)
+	}
+	if p.Page != 1 {
+		t.Errorf("cursor should replace page, got page %d", p.Page)
+	}
+
+	// A cursor signed with another secret is rejected
+	other := testListConfig
+	other.CursorSecret = []byte("other-secret")
+	if _, err := ParseListParams(httptest.NewRequest("GET", "/?cursor="+cursor, nil), other); err == nil {
+		t.Error("expected cursor from another secret to be rejected")
+	}
+
+	// So is a cursor whose payload was altered
+	payload, sig, _ := strings.Cut(cursor, ".")
+	tampered := payload[:len(payload)-1] + "x." + sig
+	if _, err := ParseListParams(httptest.NewRequest("GET", "/?cursor="+tampered, nil), testListConfig); err == nil {
+		t.Error("expected tampered cursor to be rejected")
+	}
+}
+
+func TestCursorRequiresSecret(t *testing.T) {
+	if _, err := (ListConfig{}).EncodeCursor(1); err == nil {
+		t.Error("expected error without CursorSecret")
+	}
+	_, err := ParseListParams(httptest.NewRequest("GET", "/?cursor=abc.def", nil), ListConfig{})
+	var paramErr *ParamError
+	if !errors.As(err, &paramErr) || paramErr.Message != "is not supported" {
+		t.Errorf("expected unsupported cursor error, got %v", err)
+	}
+}
+
+func TestPaginated_Cursor(t *testing.T) {
+	r := httptest.NewRequest("GET", "/bookings?limit=2&filter[status]=open", nil)
+	p, _ := ParseListParams(r, testListConfig)
+
+	w := httptest.NewRecorder()
+	Paginated[string]{Request: r, Params: p, Items: []string{"a", "b"}, NextCursor: "abc.def"}.Write(context.Background(), w)
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	body := decodeBody(t, w)
+	if !reflect.DeepEqual(body["items"], []any{"a", "b"}) || body["next_cursor"] != "abc.def" {
+		t.Errorf("unexpected body: %v", body)
+	}
+	want := `</bookings?cursor=abc.def&filter%5Bstatus%5D=open&limit=2>; rel="next"`
+	if got := w.Header().Get("Link"); got != want {
+		t.Errorf("Link:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestPaginated_Pages(t *testing.T) {
+	r := httptest.NewRequest("GET", "/bookings?page=2&limit=10", nil)
+	p, _ := ParseListParams(r, testListConfig)
+
+	w := httptest.NewRecorder()
+	Paginated[int]{Request: r, Params: p, Items: []int{1}, HasMore: true}.Write(context.Background(), w)
+
+	want := strings.Join([]string{
+		`</bookings?limit=10&page=3>; rel="next"`,
+		`</bookings?limit=10&page=1>; rel="prev"`,
+		`</bookings?limit=10>; rel="first"`,
+	}, ", ")
+	if got := w.Header().Get("Link"); got != want {
+		t.Errorf("Link:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestPaginated_LastPage(t *testing.T) {
+	r := httptest.NewRequest("GET", "/bookings", nil)
+	p, _ := ParseListParams(r, testListConfig)
+
+	w := httptest.NewRecorder()
+	Paginated[int]{Request: r, Params: p}.Write(context.Background(), w)
+
+	if got := w.Header().Get("Link"); got != "" {
+		t.Errorf("expected no Link header on a single page, got %q", got)
+	}
+	if got := strings.TrimSpace(w.Body.String()); got != `{"items":[],"next_cursor":null}` {
+		t.Errorf("unexpected body: %s", got)
+	}
+}
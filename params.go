@@ -0,0 +1,185 @@
+package bedrock
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ParamError describes a missing or malformed path or query parameter.
+// FromError converts it into a 400 response in the same format as Bind.
+//
+// Example:
+//
+//	limit, err := bedrock.QueryInt(r, "limit", 20)
+//	if err != nil {
+//	    return bedrock.FromError(err) // 400: query.limit must be an integer
+//	}
+type ParamError struct {
+	Source  string // "path" or "query"
+	Name    string // parameter name, e.g. "limit"
+	Value   string // raw value; empty if the parameter is missing
+	Message string // e.g. "must be an integer"
+}
+
+func (e *ParamError) Error() string {
+	return fmt.Sprintf("%s parameter %q %s", e.Source, e.Name, e.Message)
+}
+
+// httpError returns the 400 response for e.
+func (e *ParamError) httpError() *HTTPError {
+	return BadRequest("invalid request").
+		WithDetails([]FieldError{{Field: e.Source + "." + e.Name, Message: e.Message}}).
+		Wrap(e)
+}
+
+// PathParam returns the named path variable of the matched route, or "" if
+// there is none.
+//
+// Example:
+//
+//	// Route: GET /bookings/{id}
+//	id := bedrock.PathParam(r, "id")
+func PathParam(r *http.Request, name string) string {
+	return mux.Vars(r)[name]
+}
+
+// QueryParam returns the first value of the named query parameter, or "" if
+// it is absent.
+func QueryParam(r *http.Request, name string) string {
+	return r.URL.Query().Get(name)
+}
+
+// PathInt returns the named path variable as an int.
+// A missing or non-integer value returns a *ParamError.
+func PathInt(r *http.Request, name string) (int, error) {
+	value, ok := mux.Vars(r)[name]
+	if !ok {
+		return 0, &ParamError{Source: "path", Name: name, Message: "is required"}
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, &ParamError{Source: "path", Name: name, Value: value, Message: "must be an integer"}
+	}
+	return n, nil
+}
+
+// QueryInt returns the named query parameter as an int, or def if it is
+// absent or empty. A non-integer value returns a *ParamError.
+func QueryInt(r *http.Request, name string, def int) (int, error) {
+	value := QueryParam(r, name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, &ParamError{Source: "query", Name: name, Value: value, Message: "must be an integer"}
+	}
+	return n, nil
+}
+
+// QueryBool returns the named query parameter as a bool, or def if it is
+// absent or empty. Accepted values are those of strconv.ParseBool
+// (1, t, true, 0, f, false, ...). Anything else returns a *ParamError.
+func QueryBool(r *http.Request, name string, def bool) (bool, error) {
+	value := QueryParam(r, name)
+	if value == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, &ParamError{Source: "query", Name: name, Value: value, Message: "must be a boolean"}
+	}
+	return b, nil
+}
+
+// QueryTime returns the named query parameter as a time, or the zero time if
+// it is absent or empty. Values are RFC 3339 timestamps (2024-05-01T09:30:00Z)
+// or dates (2024-05-01, as midnight UTC). Anything else returns a *ParamError.
+func QueryTime(r *http.Request, name string) (time.Time, error) {
+	value := QueryParam(r, name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, &ParamError{Source: "query", Name: name, Value: value, Message: "must be an RFC 3339 timestamp or a date"}
+}
+
+// QueryUUID returns the named query parameter as a lowercase UUID string,
+// or "" if it is absent or empty. Values must use the canonical
+// 8-4-4-4-12 hex form. Anything else returns a *ParamError.
+func QueryUUID(r *http.Request, name string) (string, error) {
+	value := QueryParam(r, name)
+	if value == "" {
+		return "", nil
+	}
+	if !validUUID(value) {
+		return "", &ParamError{Source: "query", Name: name, Value: value, Message: "must be a UUID"}
+	}
+	return strings.ToLower(value), nil
+}
+
+// validUUID reports whether s is a UUID in canonical form.
+func validUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// QueryList returns every value of the named query parameter. Repeated
+// parameters and comma-separated values are both accepted, so
+// ?tag=a&tag=b and ?tag=a,b both return [a b]. Empty items are dropped.
+func QueryList(r *http.Request, name string) []string {
+	var list []string
+	for _, value := range r.URL.Query()[name] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+const pathRemainderKey contextKey = "pathRemainder"
+
+// PathRemainder returns the part of the request path after the matched
+// prefix of an IsPrefix route, or "" for other routes.
+//
+// Example:
+//
+//	// Route: {Method: "GET", Path: "/files/", IsPrefix: true}
+//	// GET /files/reports/2024.pdf
+//	name := bedrock.PathRemainder(r) // "reports/2024.pdf"
+func PathRemainder(r *http.Request) string {
+	rest, _ := r.Context().Value(pathRemainderKey).(string)
+	return rest
+}
+
+// withPathRemainder stores the remainder of path after prefix, which is the
+// matched part of the IsPrefix route.
+func withPathRemainder(ctx context.Context, path, prefix string) context.Context {
+	return context.WithValue(ctx, pathRemainderKey, strings.TrimPrefix(path, prefix))
+}
@@ -0,0 +1,146 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestPathParams(t *testing.T) {
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/bookings/42", nil), map[string]string{"id": "42", "slug": "abc"})
+
+	if got := PathParam(r, "slug"); got != "abc" {
+		t.Errorf("PathParam: got %q", got)
+	}
+	if n, err := PathInt(r, "id"); err != nil || n != 42 {
+		t.Errorf("PathInt: got %d, %v", n, err)
+	}
+
+	var paramErr *ParamError
+	if _, err := PathInt(r, "slug"); !errors.As(err, &paramErr) || paramErr.Message != "must be an integer" {
+		t.Errorf("expected ParamError for non-integer, got %v", err)
+	}
+	if _, err := PathInt(r, "missing"); !errors.As(err, &paramErr) || paramErr.Message != "is required" {
+		t.Errorf("expected ParamError for missing variable, got %v", err)
+	}
+}
+
+func TestQueryParams(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?limit=25&active=true&since=2024-05-01T09:30:00Z&day=2024-05-01"+
+		"&id=3F2504E0-4F89-11D3-9A0C-0305E82C3301&tag=a,b&tag=c&tag=", nil)
+
+	if got := QueryParam(r, "limit"); got != "25" {
+		t.Errorf("QueryParam: got %q", got)
+	}
+	if n, err := QueryInt(r, "limit", 10); err != nil || n != 25 {
+		t.Errorf("QueryInt: got %d, %v", n, err)
+	}
+	if n, err := QueryInt(r, "offset", 10); err != nil || n != 10 {
+		t.Errorf("QueryInt default: got %d, %v", n, err)
+	}
+	if b, err := QueryBool(r, "active", false); err != nil || !b {
+		t.Errorf("QueryBool: got %v, %v", b, err)
+	}
+	if b, err := QueryBool(r, "archived", true); err != nil || !b {
+		t.Errorf("QueryBool default: got %v, %v", b, err)
+	}
+	if ts, err := QueryTime(r, "since"); err != nil || !ts.Equal(time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("QueryTime: got %v, %v", ts, err)
+	}
+	if ts, err := QueryTime(r, "day"); err != nil || !ts.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("QueryTime date: got %v, %v", ts, err)
+	}
+	if ts, err := QueryTime(r, "until"); err != nil || !ts.IsZero() {
+		t.Errorf("QueryTime absent: got %v, %v", ts, err)
+	}
+	if id, err := QueryUUID(r, "id"); err != nil || id != "3f2504e0-4f89-11d3-9a0c-0305e82c3301" {
+		t.Errorf("QueryUUID: got %q, %v", id, err)
+	}
+	if list := QueryList(r, "tag"); !reflect.DeepEqual(list, []string{"a", "b", "c"}) {
+		t.Errorf("QueryList: got %v", list)
+	}
+	if list := QueryList(r, "none"); list != nil {
+		t.Errorf("QueryList absent: got %v", list)
+	}
+}
+
+func TestQueryParams_Invalid(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?n=ten&b=maybe&t=yesterday&id=not-a-uuid", nil)
+
+	tests := []struct {
+		name    string
+		err     error
+		message string
+	}{
+		{"n", second(QueryInt(r, "n", 0)), "must be an integer"},
+		{"b", second(QueryBool(r, "b", false)), "must be a boolean"},
+		{"t", second(QueryTime(r, "t")), "must be an RFC 3339 timestamp or a date"},
+		{"id", second(QueryUUID(r, "id")), "must be a UUID"},
+	}
+	for _, tt := range tests {
+		var paramErr *ParamError
+		if !errors.As(tt.err, &paramErr) {
+			t.Errorf("%s: expected *ParamError, got %v", tt.name, tt.err)
+			continue
+		}
+		if paramErr.Source != "query" || paramErr.Name != tt.name || paramErr.Message != tt.message {
+			t.Errorf("%s: unexpected error %+v", tt.name, paramErr)
+		}
+	}
+}
+
+func second[T any](_ T, err error) error {
+	return err
+}
+
+func TestParamError_Writes400(t *testing.T) {
+	_, err := QueryInt(httptest.NewRequest("GET", "/?limit=lots", nil), "limit", 20)
+
+	w := httptest.NewRecorder()
+	FromError(err).Write(context.Background(), w)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	body := decodeBody(t, w)
+	details, _ := body["details"].([]any)
+	if len(details) != 1 {
+		t.Fatalf("expected one field error, got %v", body)
+	}
+	field := details[0].(map[string]any)
+	if field["field"] != "query.limit" || field["message"] != "must be an integer" {
+		t.Errorf("unexpected details: %v", field)
+	}
+}
+
+func TestPathRemainder(t *testing.T) {
+	var got []string
+	handler := func(ctx context.Context, r *http.Request) Response {
+		got = append(got, PathRemainder(r))
+		return JSON(200, nil)
+	}
+	files := Group("/tenants/{tenant}")
+	files.Add(Route{Method: "GET", Path: "/files/", Handler: handler, IsPrefix: true})
+	routes := append(files.Routes(),
+		Route{Method: "GET", Path: "/static", Handler: handler, IsPrefix: true},
+		Route{Method: "GET", Path: "/exact", Handler: handler},
+	)
+
+	var buf bytes.Buffer
+	router := testRouter(routes, Options{}, &buf)
+	for _, path := range []string{"/tenants/acme/files/reports/2024.pdf", "/static/css/site.css", "/exact"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	want := []string{"reports/2024.pdf", "/css/site.css", ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -1,11 +1,150 @@
 package bedrock
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
 
 // bcryptCost defines the computational cost of the bcrypt algorithm.
 // Higher values are more secure but slower. 12 is a good balance for 2024.
 const bcryptCost = 12
 
+// Defaults used by Argon2idHasher when its fields are zero, as recommended
+// by OWASP.
+const (
+	DefaultArgon2Memory      = 19 * 1024 // KiB
+	DefaultArgon2Time        = 2
+	DefaultArgon2Parallelism = 1
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// ErrPasswordMismatch is returned when a password does not match its hash.
+var ErrPasswordMismatch = errors.New("bedrock: password does not match")
+
+// PasswordHasher hashes and checks passwords.
+//
+// Check accepts hashes of every algorithm bedrock supports, not just the
+// hasher's own, so that switching hashers keeps existing users able to log
+// in; NeedsRehash then reports their hashes as outdated.
+type PasswordHasher interface {
+	// Hash returns the encoded hash of password, with a random salt.
+	Hash(password string) (string, error)
+	// Check returns nil if password matches hash, or ErrPasswordMismatch.
+	Check(password, hash string) error
+	// NeedsRehash reports whether hash uses another algorithm or other
+	// parameters than Hash would.
+	NeedsRehash(hash string) bool
+}
+
+// Argon2idHasher hashes passwords with argon2id, encoded as PHC strings
+// such as "$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>".
+//
+// A pepper is a secret mixed into every hash and kept outside the database,
+// e.g. in a secrets manager, so that a leaked database alone cannot be
+// brute-forced. Hashes record which pepper they use (as its keyid), so a
+// pepper can be added to an existing database: older hashes still check,
+// and NeedsRehash reports them. A lost pepper cannot be recovered and makes
+// every peppered hash unusable.
+//
+// Example:
+//
+//	var passwords = bedrock.Argon2idHasher{Pepper: cfg.PasswordPepper}
+//
+//	hash, err := passwords.Hash(req.Password)
+type Argon2idHasher struct {
+	Memory      uint32 // optional; in KiB, defaults to DefaultArgon2Memory
+	Time        uint32 // optional; number of passes, defaults to DefaultArgon2Time
+	Parallelism uint8  // optional; defaults to DefaultArgon2Parallelism
+	Pepper      []byte // optional; at least 32 random bytes
+}
+
+func (h Argon2idHasher) withDefaults() Argon2idHasher {
+	if h.Memory == 0 {
+		h.Memory = DefaultArgon2Memory
+	}
+	if h.Time == 0 {
+		h.Time = DefaultArgon2Time
+	}
+	if h.Parallelism == 0 {
+		h.Parallelism = DefaultArgon2Parallelism
+	}
+	return h
+}
+
+// Hash implements PasswordHasher.
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	h = h.withDefaults()
+	params := argon2Params{memory: h.Memory, time: h.Time, parallelism: h.Parallelism, keyID: pepperID(h.Pepper)}
+	params.salt = make([]byte, argon2SaltLength)
+	if _, err := rand.Read(params.salt); err != nil {
+		return "", err
+	}
+	params.key = params.derive(pepper(password, h.Pepper), argon2KeyLength)
+	return params.String(), nil
+}
+
+// Check implements PasswordHasher.
+func (h Argon2idHasher) Check(password, hash string) error {
+	return checkPassword(password, hash, h.Pepper)
+}
+
+// NeedsRehash implements PasswordHasher.
+func (h Argon2idHasher) NeedsRehash(hash string) bool {
+	h = h.withDefaults()
+	params, err := parseArgon2(hash)
+	return err != nil || params.memory != h.Memory || params.time != h.Time ||
+		params.parallelism != h.Parallelism || params.keyID != pepperID(h.Pepper) ||
+		len(params.key) != argon2KeyLength
+}
+
+// BcryptHasher hashes passwords with bcrypt, in its usual "$2a$" format.
+// It is what HashPassword uses. bcrypt only supports passwords of up to 72
+// bytes, and Hash returns an error for longer ones; prefer Argon2idHasher
+// for new applications.
+type BcryptHasher struct {
+	Cost int // optional; defaults to 12
+}
+
+// Hash implements PasswordHasher.
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost())
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Check implements PasswordHasher.
+func (h BcryptHasher) Check(password, hash string) error {
+	return checkPassword(password, hash, nil)
+}
+
+// NeedsRehash implements PasswordHasher.
+func (h BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost()
+}
+
+func (h BcryptHasher) cost() int {
+	if h.Cost == 0 {
+		return bcryptCost
+	}
+	return h.Cost
+}
+
 // HashPassword generates a bcrypt hash of the given password.
 // The resulting hash is safe to store in a database.
 //
@@ -22,19 +161,16 @@ const bcryptCost = 12
 //	}
 //	// Store hash in database
 func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hash), nil
+	return BcryptHasher{}.Hash(password)
 }
 
-// CheckPassword verifies that a plaintext password matches a bcrypt hash.
+// CheckPassword verifies that a plaintext password matches a bcrypt or
+// (unpeppered) argon2id hash.
 // Returns nil if the password is correct, or an error if incorrect.
 //
 // Parameters:
 //   - password: The plaintext password to check
-//   - hash: The bcrypt hash to compare against
+//   - hash: The hash to compare against
 //
 // Returns nil if passwords match, error if they don't match or there's an issue.
 //
@@ -46,5 +182,145 @@ func HashPassword(password string) (string, error) {
 //	}
 //	// Password is correct, proceed with login
 func CheckPassword(password, hash string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return checkPassword(password, hash, nil)
+}
+
+// CheckAndUpgradePassword checks password against hash like hasher.Check.
+// If it matches but hash is outdated, e.g. bcrypt after switching to
+// argon2id, or argon2id with less memory than now configured, it also
+// returns a new hash to store; otherwise the new hash is "".
+//
+// Example:
+//
+//	newHash, err := bedrock.CheckAndUpgradePassword(a.passwords, req.Password, user.PasswordHash)
+//	if errors.Is(err, bedrock.ErrPasswordMismatch) {
+//	    return bedrock.Unauthorized("invalid credentials")
+//	}
+//	if err != nil {
+//	    return bedrock.FromError(err)
+//	}
+//	if newHash != "" {
+//	    err = a.db.SetPasswordHash(ctx, user.ID, newHash)
+//	}
+func CheckAndUpgradePassword(hasher PasswordHasher, password, hash string) (string, error) {
+	if err := hasher.Check(password, hash); err != nil {
+		return "", err
+	}
+	if !hasher.NeedsRehash(hash) {
+		return "", nil
+	}
+	return hasher.Hash(password)
+}
+
+// checkPassword checks password against a hash of any supported algorithm.
+func checkPassword(password, hash string, pepperKey []byte) error {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, err := parseArgon2(hash)
+		if err != nil {
+			return err
+		}
+		if params.keyID != "" && params.keyID != pepperID(pepperKey) {
+			return errors.New("bedrock: password hash uses another pepper")
+		}
+		if params.keyID == "" {
+			pepperKey = nil // hashed before the pepper was added
+		}
+		key := params.derive(pepper(password, pepperKey), uint32(len(params.key)))
+		if subtle.ConstantTimeCompare(key, params.key) != 1 {
+			return ErrPasswordMismatch
+		}
+		return nil
+	case strings.HasPrefix(hash, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			// Callers from before ErrPasswordMismatch may check bcrypt's error
+			return fmt.Errorf("%w: %w", ErrPasswordMismatch, err)
+		}
+		return err
+	}
+	return errors.New("bedrock: unknown password hash format")
+}
+
+// pepper returns the input to hash for password: password itself, or its
+// HMAC-SHA256 with pepperKey.
+func pepper(password string, pepperKey []byte) []byte {
+	if len(pepperKey) == 0 {
+		return []byte(password)
+	}
+	mac := hmac.New(sha256.New, pepperKey)
+	mac.Write([]byte(password))
+	return mac.Sum(nil)
+}
+
+// pepperID identifies a pepper in hashes without revealing it.
+func pepperID(pepperKey []byte) string {
+	if len(pepperKey) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(pepperKey)
+	return base64.RawStdEncoding.EncodeToString(sum[:6])
+}
+
+// argon2Params are the fields of an argon2id PHC string.
+type argon2Params struct {
+	memory      uint32
+	time        uint32
+	parallelism uint8
+	keyID       string
+	salt, key   []byte
+}
+
+func (p argon2Params) derive(password []byte, keyLength uint32) []byte {
+	return argon2.IDKey(password, p.salt, p.time, p.memory, p.parallelism, keyLength)
+}
+
+func (p argon2Params) String() string {
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", p.memory, p.time, p.parallelism)
+	if p.keyID != "" {
+		params += ",keyid=" + p.keyID
+	}
+	return fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", argon2.Version, params,
+		base64.RawStdEncoding.EncodeToString(p.salt), base64.RawStdEncoding.EncodeToString(p.key))
+}
+
+func parseArgon2(hash string) (argon2Params, error) {
+	var p argon2Params
+	invalid := errors.New("bedrock: invalid argon2id hash")
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" || parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return p, invalid
+	}
+	for _, param := range strings.Split(parts[3], ",") {
+		name, value, _ := strings.Cut(param, "=")
+		var err error
+		switch name {
+		case "m":
+			_, err = fmt.Sscan(value, &p.memory)
+		case "t":
+			_, err = fmt.Sscan(value, &p.time)
+		case "p":
+			_, err = fmt.Sscan(value, &p.parallelism)
+		case "keyid":
+			p.keyID = value
+		default:
+			err = invalid
+		}
+		if err != nil {
+			return p, invalid
+		}
+	}
+
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, invalid
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return p, invalid
+	}
+	if p.memory == 0 || p.time == 0 || p.parallelism == 0 || len(p.key) < 16 {
+		return p, invalid
+	}
+	return p, nil
 }
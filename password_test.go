@@ -0,0 +1,141 @@
+package bedrock
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fastArgon2 keeps tests quick; real deployments use the defaults or more.
+var fastArgon2 = Argon2idHasher{Memory: 64, Time: 1}
+
+func TestArgon2idHasher(t *testing.T) {
+	hash, err := Argon2idHasher{}.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Errorf("unexpected PHC string %q", hash)
+	}
+	if err := (Argon2idHasher{}).Check("correct horse", hash); err != nil {
+		t.Errorf("expected password to match, got %v", err)
+	}
+	if err := (Argon2idHasher{}).Check("wrong horse", hash); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("expected ErrPasswordMismatch, got %v", err)
+	}
+	if other, _ := (Argon2idHasher{}).Hash("correct horse"); other == hash {
+		t.Error("expected a random salt")
+	}
+
+	// Passwords beyond bcrypt's 72-byte limit are not truncated
+	long := strings.Repeat("a", 100)
+	hash, _ = fastArgon2.Hash(long)
+	if err := fastArgon2.Check(long[:72], hash); err == nil {
+		t.Error("expected a truncated password not to match")
+	}
+}
+
+func TestArgon2idHasher_Pepper(t *testing.T) {
+	peppered := Argon2idHasher{Memory: 64, Time: 1, Pepper: bytes.Repeat([]byte{1}, 32)}
+
+	hash, _ := peppered.Hash("correct horse")
+	if !strings.Contains(hash, ",keyid=") {
+		t.Errorf("expected the pepper's keyid in %q", hash)
+	}
+	if err := peppered.Check("correct horse", hash); err != nil {
+		t.Errorf("expected password to match, got %v", err)
+	}
+	if err := fastArgon2.Check("correct horse", hash); err == nil || errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("expected an error without the pepper, got %v", err)
+	}
+	rotated := peppered
+	rotated.Pepper = bytes.Repeat([]byte{2}, 32)
+	if err := rotated.Check("correct horse", hash); err == nil || errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("expected an error with another pepper, got %v", err)
+	}
+
+	// Hashes from before the pepper was added still check, and get upgraded
+	old, _ := fastArgon2.Hash("correct horse")
+	if err := peppered.Check("correct horse", old); err != nil {
+		t.Errorf("expected unpeppered hash to match, got %v", err)
+	}
+	if !peppered.NeedsRehash(old) || peppered.NeedsRehash(hash) {
+		t.Error("expected only the unpeppered hash to need a rehash")
+	}
+}
+
+func TestPasswordHasher_NeedsRehash(t *testing.T) {
+	bcryptHash, _ := BcryptHasher{Cost: 4}.Hash("correct horse")
+	argonHash, _ := fastArgon2.Hash("correct horse")
+
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+		hash   string
+		want   bool
+	}{
+		{"same bcrypt cost", BcryptHasher{Cost: 4}, bcryptHash, false},
+		{"higher bcrypt cost", BcryptHasher{Cost: 5}, bcryptHash, true},
+		{"default bcrypt cost", BcryptHasher{}, bcryptHash, true},
+		{"bcrypt to argon2id", fastArgon2, bcryptHash, true},
+		{"same argon2id parameters", fastArgon2, argonHash, false},
+		{"more memory", Argon2idHasher{Memory: 128, Time: 1}, argonHash, true},
+		{"more passes", Argon2idHasher{Memory: 64, Time: 2}, argonHash, true},
+		{"argon2id to bcrypt", BcryptHasher{Cost: 4}, argonHash, true},
+		{"garbage", fastArgon2, "not a hash", true},
+	}
+	for _, tt := range tests {
+		if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestCheckAndUpgradePassword(t *testing.T) {
+	legacy, _ := BcryptHasher{Cost: 4}.Hash("correct horse")
+
+	// A bcrypt hash checks with the argon2id hasher and is upgraded
+	newHash, err := CheckAndUpgradePassword(fastArgon2, "correct horse", legacy)
+	if err != nil || !strings.HasPrefix(newHash, "$argon2id$") {
+		t.Fatalf("expected an argon2id hash, got %q, %v", newHash, err)
+	}
+	if err := fastArgon2.Check("correct horse", newHash); err != nil {
+		t.Errorf("expected upgraded hash to match, got %v", err)
+	}
+
+	// Up-to-date hashes are left alone
+	if again, err := CheckAndUpgradePassword(fastArgon2, "correct horse", newHash); err != nil || again != "" {
+		t.Errorf("expected no upgrade, got %q, %v", again, err)
+	}
+
+	// Wrong passwords are never upgraded
+	if upgraded, err := CheckAndUpgradePassword(fastArgon2, "wrong horse", legacy); !errors.Is(err, ErrPasswordMismatch) || upgraded != "" {
+		t.Errorf("expected ErrPasswordMismatch, got %q, %v", upgraded, err)
+	}
+}
+
+func TestCheckPassword_Formats(t *testing.T) {
+	argonHash, _ := fastArgon2.Hash("correct horse")
+	if err := CheckPassword("correct horse", argonHash); err != nil {
+		t.Errorf("expected CheckPassword to accept argon2id hashes, got %v", err)
+	}
+	if err := CheckPassword("correct horse", "$argon2id$v=19$m=64,t=1,p=1$bad$hash"); err == nil {
+		t.Error("expected error for a malformed hash")
+	}
+	if err := CheckPassword("correct horse", "plaintext"); err == nil {
+		t.Error("expected error for an unknown format")
+	}
+	if _, err := HashPassword(strings.Repeat("a", 73)); err == nil {
+		t.Error("expected bcrypt to refuse passwords over 72 bytes")
+	}
+
+	// Wrong bcrypt passwords match both sentinels, for older callers
+	bcryptHash, _ := BcryptHasher{Cost: 4}.Hash("correct horse")
+	err := CheckPassword("wrong horse", bcryptHash)
+	if !errors.Is(err, ErrPasswordMismatch) || !errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		t.Errorf("expected ErrPasswordMismatch and bcrypt.ErrMismatchedHashAndPassword, got %v", err)
+	}
+}
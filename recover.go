@@ -0,0 +1,48 @@
+package bedrock
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// PanicHandler is called after bedrock recovers a panic in a route handler.
+// Use it to forward panics to an error tracker such as Sentry, the same way
+// Job.OnError is used for scheduled jobs.
+type PanicHandler func(ctx context.Context, r *http.Request, recovered any, stack []byte)
+
+// recoverPanic must be deferred by the route adapter. It logs a recovered
+// panic with its stack through the request-scoped logger, calls onPanic,
+// and writes a JSON 500 if the response hasn't been started yet.
+//
+// http.ErrAbortHandler is re-panicked so net/http can abort the response.
+func recoverPanic(ctx context.Context, w http.ResponseWriter, r *http.Request, onPanic PanicHandler) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	if recovered == http.ErrAbortHandler {
+		panic(recovered)
+	}
+
+	stack := debug.Stack()
+	Logger(ctx).Error("panic in handler",
+		"panic", fmt.Sprint(recovered),
+		"method", r.Method,
+		"path", r.URL.Path,
+		"stack", string(stack),
+	)
+
+	if onPanic != nil {
+		onPanic(ctx, r, recovered, stack)
+	}
+
+	// If the handler already started writing, the status line is gone;
+	// the best we can do is stop writing.
+	if rec, ok := w.(*responseRecorder); ok && rec.status != 0 {
+		return
+	}
+
+	InternalError().Write(ctx, w)
+}
@@ -0,0 +1,138 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func panickingRoute(path string) Route {
+	return Route{
+		Method: "GET",
+		Path:   path,
+		Handler: func(ctx context.Context, r *http.Request) Response {
+			panic("something broke")
+		},
+	}
+}
+
+func TestRecover_ReturnsJSON500(t *testing.T) {
+	var buf bytes.Buffer
+	router := testRouter([]Route{panickingRoute("/boom")}, Options{}, &buf)
+
+	req := httptest.NewRequest("GET", "/boom", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type: got %q, want application/json", got)
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	if body["error"] != "internal server error" || body["request_id"] != "req-1" {
+		t.Errorf("unexpected body: %v", body)
+	}
+
+	lines := logLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("expected panic log and access log, got %d lines", len(lines))
+	}
+	panicLog, access := lines[0], lines[1]
+	if panicLog["msg"] != "panic in handler" || panicLog["panic"] != "something broke" || panicLog["request_id"] != "req-1" {
+		t.Errorf("unexpected panic log: %v", panicLog)
+	}
+	if stack, _ := panicLog["stack"].(string); !strings.Contains(stack, "recover_test.go") {
+		t.Errorf("panic log should include the stack, got %q", stack)
+	}
+	if access["status"] != float64(500) {
+		t.Errorf("access log status: got %v, want 500", access["status"])
+	}
+}
+
+func TestRecover_CallsOnPanic(t *testing.T) {
+	var gotValue any
+	var gotStack []byte
+	var gotRequestID string
+	opts := Options{
+		OnPanic: func(ctx context.Context, r *http.Request, recovered any, stack []byte) {
+			gotValue, gotStack = recovered, stack
+			gotRequestID, _ = GetRequestID(ctx)
+		},
+	}
+
+	var buf bytes.Buffer
+	router := testRouter([]Route{panickingRoute("/boom")}, opts, &buf)
+
+	req := httptest.NewRequest("GET", "/boom", nil)
+	req.Header.Set("X-Request-ID", "req-2")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if gotValue != "something broke" {
+		t.Errorf("OnPanic value: got %v", gotValue)
+	}
+	if len(gotStack) == 0 {
+		t.Error("OnPanic should receive the stack")
+	}
+	if gotRequestID != "req-2" {
+		t.Errorf("OnPanic request ID: got %q, want req-2", gotRequestID)
+	}
+}
+
+// panicAfterWrite writes a partial body and then panics.
+type panicAfterWrite struct{}
+
+func (panicAfterWrite) Write(ctx context.Context, w http.ResponseWriter) error {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("partial"))
+	panic("mid-write")
+}
+
+func TestRecover_AfterHeadersWritten(t *testing.T) {
+	routes := []Route{{
+		Method: "GET",
+		Path:   "/stream",
+		Handler: func(ctx context.Context, r *http.Request) Response {
+			return panicAfterWrite{}
+		},
+	}}
+
+	var buf bytes.Buffer
+	router := testRouter(routes, Options{}, &buf)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stream", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "partial" {
+		t.Errorf("response should be left as written, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRecover_ReraisesAbortHandler(t *testing.T) {
+	routes := []Route{{
+		Method: "GET",
+		Path:   "/abort",
+		Handler: func(ctx context.Context, r *http.Request) Response {
+			panic(http.ErrAbortHandler)
+		},
+	}}
+
+	var buf bytes.Buffer
+	router := testRouter(routes, Options{}, &buf)
+
+	defer func() {
+		if recover() != http.ErrAbortHandler {
+			t.Error("http.ErrAbortHandler should be re-panicked")
+		}
+	}()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+}
@@ -0,0 +1,311 @@
+package bedrock
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults used by RefreshConfig when its TTLs are zero.
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrTokenNotFound is returned by a TokenStore for unknown refresh tokens.
+	ErrTokenNotFound = errors.New("bedrock: refresh token not found")
+	// ErrInvalidRefreshToken is returned for unknown, expired or revoked refresh tokens.
+	ErrInvalidRefreshToken = errors.New("bedrock: invalid refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token
+	// is presented again. Its whole family has been revoked.
+	ErrRefreshTokenReused = errors.New("bedrock: refresh token reused")
+)
+
+// RefreshToken is a refresh token as kept by a TokenStore. The token itself
+// is never stored, only its hash.
+type RefreshToken struct {
+	Hash      string // hex SHA-256 of the token
+	UserID    string
+	FamilyID  string // shared by every token rotated from the same login
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Used      bool // set once the token has been rotated
+}
+
+// TokenStore persists refresh tokens.
+//
+// Consume must be atomic: of two concurrent calls for the same hash, only
+// one may see Used == false, otherwise a stolen token could be rotated in
+// parallel with the legitimate one without being detected.
+type TokenStore interface {
+	// Save stores a new token.
+	Save(ctx context.Context, token RefreshToken) error
+	// Consume marks the token with hash as used and returns it as it was
+	// before, or ErrTokenNotFound.
+	Consume(ctx context.Context, hash string) (RefreshToken, error)
+	// RevokeFamily deletes every token of a family.
+	RevokeFamily(ctx context.Context, familyID string) error
+}
+
+// TokenPair is the response of a login or refresh.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"` // always "Bearer"
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+}
+
+// RefreshConfig issues short-lived access tokens (see GenerateJWT) together
+// with long-lived, opaque refresh tokens.
+//
+// Every refresh rotates the refresh token: the old one is marked used and a
+// new one of the same family is returned. If a used token is presented
+// again, it was most likely stolen, so the whole family is revoked and both
+// the thief and the user must log in again.
+//
+// Example:
+//
+//	var tokens = bedrock.RefreshConfig{
+//	    Store:  bedrock.NewMemoryTokenStore(),
+//	    Secret: cfg.JWTSecret,
+//	}
+//
+//	func (a *MyApp) login(ctx context.Context, r *http.Request) bedrock.Response {
+//	    user, err := a.authenticate(ctx, r)
+//	    if err != nil {
+//	        return bedrock.Unauthorized("invalid credentials")
+//	    }
+//	    pair, err := tokens.Issue(ctx, user.ID)
+//	    if err != nil {
+//	        return bedrock.FromError(err)
+//	    }
+//	    return bedrock.JSON(200, pair)
+//	}
+//
+//	routes := []bedrock.Route{
+//	    {Method: "POST", Path: "/auth/login", Handler: a.login},
+//	    {Method: "POST", Path: "/auth/refresh", Handler: tokens.RefreshHandler()},
+//	    {Method: "POST", Path: "/auth/logout", Handler: tokens.LogoutHandler()},
+//	}
+type RefreshConfig struct {
+	Store      TokenStore
+	Secret     string        // signs access tokens
+	AccessTTL  time.Duration // optional; defaults to DefaultAccessTokenTTL
+	RefreshTTL time.Duration // optional; defaults to DefaultRefreshTokenTTL
+}
+
+// Issue starts a new token family for userID, typically after login.
+func (cfg RefreshConfig) Issue(ctx context.Context, userID string) (TokenPair, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return cfg.issue(ctx, userID, familyID)
+}
+
+// Refresh rotates refreshToken and returns a new token pair. It returns
+// ErrInvalidRefreshToken for unknown, expired or revoked tokens, and
+// ErrRefreshTokenReused after revoking the family of a reused token.
+func (cfg RefreshConfig) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
+	stored, err := cfg.Store.Consume(ctx, hashToken(refreshToken))
+	if errors.Is(err, ErrTokenNotFound) {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	if stored.Used {
+		if err := cfg.Store.RevokeFamily(ctx, stored.FamilyID); err != nil {
+			return TokenPair{}, err
+		}
+		Logger(ctx).Warn("refresh token reused, family revoked", "user_id", stored.UserID, "family_id", stored.FamilyID)
+		return TokenPair{}, ErrRefreshTokenReused
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	return cfg.issue(ctx, stored.UserID, stored.FamilyID)
+}
+
+// Revoke revokes the family of refreshToken, logging the session out.
+// Unknown tokens are ignored.
+func (cfg RefreshConfig) Revoke(ctx context.Context, refreshToken string) error {
+	// Consume also tells us the family; the token is revoked either way
+	stored, err := cfg.Store.Consume(ctx, hashToken(refreshToken))
+	if errors.Is(err, ErrTokenNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return cfg.Store.RevokeFamily(ctx, stored.FamilyID)
+}
+
+func (cfg RefreshConfig) issue(ctx context.Context, userID, familyID string) (TokenPair, error) {
+	accessTTL, refreshTTL := cfg.AccessTTL, cfg.RefreshTTL
+	if accessTTL == 0 {
+		accessTTL = DefaultAccessTokenTTL
+	}
+	if refreshTTL == 0 {
+		refreshTTL = DefaultRefreshTokenTTL
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	now := time.Now()
+	err = cfg.Store.Save(ctx, RefreshToken{
+		Hash:      hashToken(refreshToken),
+		UserID:    userID,
+		FamilyID:  familyID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(refreshTTL),
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	accessToken, err := GenerateJWT(userID, cfg.Secret, accessTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTTL.Seconds()),
+	}, nil
+}
+
+// refreshRequest is the body of the refresh and logout endpoints.
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// RefreshHandler handles POST {"refresh_token": "..."} and responds with a
+// new TokenPair, or 401 if the refresh token is invalid or was reused.
+func (cfg RefreshConfig) RefreshHandler() Handler {
+	return func(ctx context.Context, r *http.Request) Response {
+		var req refreshRequest
+		if err := DecodeAndValidate(r, &req, 0); err != nil {
+			return FromError(err)
+		}
+
+		pair, err := cfg.Refresh(ctx, req.RefreshToken)
+		if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, ErrRefreshTokenReused) {
+			return Unauthorized("invalid refresh token")
+		}
+		if err != nil {
+			return FromError(err)
+		}
+		return JSONWithHeaders(http.StatusOK, pair, http.Header{"Cache-Control": {"no-store"}})
+	}
+}
+
+// LogoutHandler handles POST {"refresh_token": "..."}, revokes the token's
+// family and responds with 204. Access tokens already issued stay valid
+// until they expire.
+func (cfg RefreshConfig) LogoutHandler() Handler {
+	return func(ctx context.Context, r *http.Request) Response {
+		var req refreshRequest
+		if err := DecodeAndValidate(r, &req, 0); err != nil {
+			return FromError(err)
+		}
+		if err := cfg.Revoke(ctx, req.RefreshToken); err != nil {
+			return FromError(err)
+		}
+		return noContent{}
+	}
+}
+
+// noContent is a 204 response.
+type noContent struct{}
+
+func (noContent) Write(ctx context.Context, w http.ResponseWriter) error {
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// randomToken returns n random bytes, base64url-encoded.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex SHA-256 of a token. Refresh tokens are random
+// and long, so a fast hash is enough to make a leaked store useless.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryTokenStore is an in-memory TokenStore, for tests and single-instance
+// deployments. Tokens are lost on restart.
+type MemoryTokenStore struct {
+	mu        sync.Mutex
+	tokens    map[string]*RefreshToken
+	lastSweep time.Time
+}
+
+// NewMemoryTokenStore returns an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: make(map[string]*RefreshToken)}
+}
+
+// Save implements TokenStore. Expired tokens are purged, at most once a
+// minute, as a side effect.
+func (s *MemoryTokenStore) Save(ctx context.Context, token RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now := time.Now(); now.Sub(s.lastSweep) > time.Minute {
+		for hash, t := range s.tokens {
+			if now.After(t.ExpiresAt) {
+				delete(s.tokens, hash)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.tokens[token.Hash] = &token
+	return nil
+}
+
+// Consume implements TokenStore.
+func (s *MemoryTokenStore) Consume(ctx context.Context, hash string) (RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[hash]
+	if !ok {
+		return RefreshToken{}, ErrTokenNotFound
+	}
+	before := *t
+	t.Used = true
+	return before, nil
+}
+
+// RevokeFamily implements TokenStore.
+func (s *MemoryTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, t := range s.tokens {
+		if t.FamilyID == familyID {
+			delete(s.tokens, hash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package bedrock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testRefreshConfig() RefreshConfig {
+	return RefreshConfig{Store: NewMemoryTokenStore(), Secret: "test-secret"}
+}
+
+func TestRefreshConfig_IssueAndRotate(t *testing.T) {
+	cfg := testRefreshConfig()
+	ctx := context.Background()
+
+	pair, err := cfg.Issue(ctx, "user123")
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if userID, err := ValidateJWT(pair.AccessToken, cfg.Secret); err != nil || userID != "user123" {
+		t.Errorf("access token: got %q, %v", userID, err)
+	}
+	if pair.TokenType != "Bearer" || pair.ExpiresIn != int(DefaultAccessTokenTTL.Seconds()) {
+		t.Errorf("unexpected pair: %+v", pair)
+	}
+
+	// Only the hash is stored
+	store := cfg.Store.(*MemoryTokenStore)
+	for hash := range store.tokens {
+		if hash == pair.RefreshToken || strings.Contains(hash, pair.RefreshToken) {
+			t.Error("refresh token stored in plain text")
+		}
+	}
+
+	rotated, err := cfg.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if rotated.RefreshToken == pair.RefreshToken {
+		t.Error("expected a new refresh token")
+	}
+	if _, err := cfg.Refresh(ctx, rotated.RefreshToken); err != nil {
+		t.Errorf("rotated token should refresh: %v", err)
+	}
+}
+
+func TestRefreshConfig_ReuseRevokesFamily(t *testing.T) {
+	cfg := testRefreshConfig()
+	ctx := context.Background()
+
+	stolen, _ := cfg.Issue(ctx, "user123")
+	other, _ := cfg.Issue(ctx, "user123") // another device, another family
+	current, _ := cfg.Refresh(ctx, stolen.RefreshToken)
+
+	if _, err := cfg.Refresh(ctx, stolen.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := cfg.Refresh(ctx, current.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected the family to be revoked, got %v", err)
+	}
+	if _, err := cfg.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("other families should be unaffected: %v", err)
+	}
+}
+
+func TestRefreshConfig_ConcurrentRefresh(t *testing.T) {
+	cfg := testRefreshConfig()
+	ctx := context.Background()
+	pair, _ := cfg.Issue(ctx, "user123")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Go(func() {
+			_, err := cfg.Refresh(ctx, pair.RefreshToken)
+			errs <- err
+		})
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("expected exactly one refresh to succeed, got %d", succeeded)
+	}
+}
+
+func TestRefreshConfig_Expired(t *testing.T) {
+	cfg := testRefreshConfig()
+	cfg.RefreshTTL = -time.Second
+	pair, _ := cfg.Issue(context.Background(), "user123")
+
+	if _, err := cfg.Refresh(context.Background(), pair.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+	if _, err := cfg.Refresh(context.Background(), "made-up"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("expected ErrInvalidRefreshToken for unknown token, got %v", err)
+	}
+}
+
+func TestRefreshHandlers(t *testing.T) {
+	cfg := testRefreshConfig()
+	ctx := context.Background()
+	pair, _ := cfg.Issue(ctx, "user123")
+
+	post := func(handler Handler, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(ctx, httptest.NewRequest("POST", "/", strings.NewReader(body))).Write(ctx, w)
+		return w
+	}
+
+	w := post(cfg.RefreshHandler(), `{"refresh_token": "`+pair.RefreshToken+`"}`)
+	if w.Code != 200 || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("refresh: expected 200 with no-store, got %d %v", w.Code, w.Header())
+	}
+	var rotated TokenPair
+	json.NewDecoder(w.Body).Decode(&rotated)
+	if rotated.AccessToken == "" || rotated.RefreshToken == "" {
+		t.Fatalf("unexpected body: %+v", rotated)
+	}
+
+	if w := post(cfg.RefreshHandler(), `{"refresh_token": "`+pair.RefreshToken+`"}`); w.Code != 401 {
+		t.Errorf("reuse: expected 401, got %d", w.Code)
+	}
+	if w := post(cfg.RefreshHandler(), `{}`); w.Code != 422 {
+		t.Errorf("missing token: expected 422, got %d", w.Code)
+	}
+
+	pair, _ = cfg.Issue(ctx, "user123")
+	if w := post(cfg.LogoutHandler(), `{"refresh_token": "`+pair.RefreshToken+`"}`); w.Code != 204 || w.Body.Len() != 0 {
+		t.Errorf("logout: expected empty 204, got %d %q", w.Code, w.Body.String())
+	}
+	if w := post(cfg.RefreshHandler(), `{"refresh_token": "`+pair.RefreshToken+`"}`); w.Code != 401 {
+		t.Errorf("after logout: expected 401, got %d", w.Code)
+	}
+	if w := post(cfg.LogoutHandler(), `{"refresh_token": "unknown"}`); w.Code != 204 {
+		t.Errorf("logout is idempotent: expected 204, got %d", w.Code)
+	}
+}
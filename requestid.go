@@ -0,0 +1,113 @@
+package bedrock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// DefaultRequestIDHeader is the header used for request IDs when none is configured.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps incoming request IDs so clients can't bloat logs.
+const maxRequestIDLength = 128
+
+const requestIDKey contextKey = "requestID"
+
+// RequestID creates middleware that assigns every request a correlation ID.
+// It reuses an ID already in the context, then accepts a well-formed incoming
+// ID from header (DefaultRequestIDHeader if empty), and otherwise generates one.
+// The ID is stored in the context and echoed in the response headers.
+//
+// Bedrock applies the same logic to every route automatically (see
+// Options.RequestIDHeader), so this middleware is only needed for handlers
+// served outside RunContext.
+//
+// Usage:
+//
+//	handler := bedrock.Chain(myHandler, bedrock.RequestID(""))
+func RequestID(header string) Middleware {
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, r *http.Request) Response {
+			requestID, ok := GetRequestID(ctx)
+			if !ok {
+				requestID = incomingRequestID(r, header)
+				ctx = WithRequestID(ctx, requestID)
+			}
+			return headerResponse{
+				Response: next(ctx, r),
+				header:   header,
+				value:    requestID,
+			}
+		}
+	}
+}
+
+// WithRequestID adds a request ID to the context.
+// This is typically called by bedrock before the route handler runs.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// GetRequestID extracts the request ID from the request context.
+// Returns the request ID and a boolean indicating if it was found.
+//
+// Example:
+//
+//	func MyHandler(ctx context.Context, r *http.Request) bedrock.Response {
+//	    requestID, _ := bedrock.GetRequestID(ctx)
+//	    // Pass requestID to downstream services...
+//	}
+func GetRequestID(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey).(string)
+	return requestID, ok
+}
+
+// incomingRequestID returns the request ID from header if it is well formed,
+// or a newly generated one.
+func incomingRequestID(r *http.Request, header string) string {
+	if id := r.Header.Get(header); validRequestID(id) {
+		return id
+	}
+	return newRequestID()
+}
+
+// newRequestID returns a random 128-bit hex request ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID accepts IDs made of letters, digits and "-_.:",
+// which covers UUIDs, ULIDs and hex IDs from proxies and other services.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// headerResponse wraps a Response and sets a single header before writing it.
+type headerResponse struct {
+	Response
+	header string
+	value  string
+}
+
+func (r headerResponse) Write(ctx context.Context, w http.ResponseWriter) error {
+	w.Header().Set(r.header, r.value)
+	return r.Response.Write(ctx, w)
+}
@@ -0,0 +1,138 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddleware_GeneratesID(t *testing.T) {
+	var seen string
+	handler := Chain(func(ctx context.Context, r *http.Request) Response {
+		seen, _ = GetRequestID(ctx)
+		return JSON(200, nil)
+	}, RequestID(""))
+
+	w := httptest.NewRecorder()
+	handler(context.Background(), httptest.NewRequest("GET", "/", nil)).Write(context.Background(), w)
+
+	if len(seen) != 32 {
+		t.Fatalf("expected generated 32-char ID, got %q", seen)
+	}
+	if got := w.Header().Get("X-Request-ID"); got != seen {
+		t.Errorf("response header: got %q, want %q", got, seen)
+	}
+}
+
+func TestRequestIDMiddleware_AcceptsIncomingHeader(t *testing.T) {
+	var seen string
+	handler := Chain(func(ctx context.Context, r *http.Request) Response {
+		seen, _ = GetRequestID(ctx)
+		return JSON(200, nil)
+	}, RequestID("X-Correlation-ID"))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Correlation-ID", "550e8400-e29b-41d4-a716-446655440000")
+	w := httptest.NewRecorder()
+	handler(context.Background(), req).Write(context.Background(), w)
+
+	if seen != "550e8400-e29b-41d4-a716-446655440000" {
+		t.Errorf("expected incoming ID, got %q", seen)
+	}
+	if got := w.Header().Get("X-Correlation-ID"); got != seen {
+		t.Errorf("response header: got %q, want %q", got, seen)
+	}
+}
+
+func TestRequestIDMiddleware_RejectsMalformedID(t *testing.T) {
+	for _, id := range []string{"has spaces", "quote\"d", strings.Repeat("a", 129)} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-ID", id)
+
+		if got := incomingRequestID(req, "X-Request-ID"); got == id {
+			t.Errorf("malformed ID %q should be replaced", id)
+		}
+	}
+}
+
+func TestRouter_RequestIDPropagation(t *testing.T) {
+	var seen string
+	routes := []Route{{
+		Method: "GET",
+		Path:   "/hello",
+		Handler: func(ctx context.Context, r *http.Request) Response {
+			seen, _ = GetRequestID(ctx)
+			return JSON(200, nil)
+		},
+	}}
+
+	var buf bytes.Buffer
+	router := testRouter(routes, Options{}, &buf)
+
+	req := httptest.NewRequest("GET", "/hello", nil)
+	req.Header.Set("X-Request-ID", "upstream-123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if seen != "upstream-123" {
+		t.Errorf("handler request ID: got %q, want upstream-123", seen)
+	}
+	if got := w.Header().Get("X-Request-ID"); got != "upstream-123" {
+		t.Errorf("response header: got %q, want upstream-123", got)
+	}
+	if lines := logLines(t, &buf); lines[0]["request_id"] != "upstream-123" {
+		t.Errorf("access log request_id: got %v", lines[0]["request_id"])
+	}
+}
+
+func TestRouter_RequestIDOnErrorBodies(t *testing.T) {
+	routes := []Route{
+		{
+			Method:     "GET",
+			Path:       "/protected",
+			Middleware: []Middleware{RequireAuth("secret")},
+			Handler: func(ctx context.Context, r *http.Request) Response {
+				return JSON(200, nil)
+			},
+		},
+		{
+			Method: "GET",
+			Path:   "/fail",
+			Handler: func(ctx context.Context, r *http.Request) Response {
+				return Error(map[string]string{"error": "boom"})
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	router := testRouter(routes, Options{}, &buf)
+
+	for _, path := range []string{"/protected", "/fail"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Request-ID", "trace-me")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var body map[string]string
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: invalid body: %v", path, err)
+		}
+		if body["request_id"] != "trace-me" || body["error"] == "" {
+			t.Errorf("%s: expected error with request_id, got %v", path, body)
+		}
+	}
+}
+
+func TestJSON_DoesNotAddRequestID(t *testing.T) {
+	ctx := WithRequestID(context.Background(), "abc")
+	w := httptest.NewRecorder()
+	JSON(200, map[string]string{"ok": "true"}).Write(ctx, w)
+
+	if strings.Contains(w.Body.String(), "request_id") {
+		t.Errorf("success bodies should not be modified: %s", w.Body.String())
+	}
+}
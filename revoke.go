@@ -0,0 +1,117 @@
+package bedrock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const revokerKey contextKey = "revoker"
+
+// Revoker decides whether a validly signed token has been revoked. Set
+// Options.Revoker and every auth middleware consults it after verifying
+// the token's signature and expiry; revoked tokens get a 401.
+type Revoker interface {
+	IsRevoked(ctx context.Context, claims jwt.RegisteredClaims) (bool, error)
+}
+
+// Denylist is an in-memory Revoker. Individual tokens are revoked by their
+// jti until they expire, and IssuedBefore can invalidate every token of a
+// user issued before a point in time.
+//
+// Entries are kept in memory only, so each instance of a service needs its
+// own calls to Revoke, and a restart forgets them. IssuedBefore is usually
+// backed by the database and does not have these limits. The zero value is
+// an empty Denylist, like NewDenylist.
+//
+// Example:
+//
+//	denylist := bedrock.NewDenylist()
+//	denylist.IssuedBefore = func(ctx context.Context, userID string) (time.Time, error) {
+//	    return a.db.PasswordChangedAt(ctx, userID)
+//	}
+//
+//	bedrock.RunWithOptions(app, cfg, bedrock.Options{Revoker: denylist})
+//
+//	// On logout, revoke the access token of the request
+//	claims, _ := bedrock.GetClaims[jwt.RegisteredClaims](ctx)
+//	var expiresAt time.Time // zero: the token has no exp
+//	if claims.ExpiresAt != nil {
+//	    expiresAt = claims.ExpiresAt.Time
+//	}
+//	denylist.Revoke(claims.ID, expiresAt)
+type Denylist struct {
+	// IssuedBefore, if set, returns the time before which the tokens of
+	// userID are invalid, e.g. when the account was disabled or its
+	// password changed; zero if none. Like iat, it is compared at second
+	// precision: tokens issued in the same second remain valid.
+	IssuedBefore func(ctx context.Context, userID string) (time.Time, error)
+
+	mu        sync.Mutex
+	revoked   map[string]time.Time // jti -> token expiry, zero if none
+	lastSweep time.Time
+}
+
+// NewDenylist returns an empty Denylist.
+func NewDenylist() *Denylist {
+	return &Denylist{revoked: make(map[string]time.Time)}
+}
+
+// Revoke revokes the token with the given jti. The entry is evicted once
+// the token expires at expiresAt, when it would be rejected anyway. A zero
+// expiresAt is for tokens without exp: they are revoked for as long as the
+// Denylist lives.
+func (d *Denylist) Revoke(jti string, expiresAt time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.revoked == nil {
+		d.revoked = make(map[string]time.Time)
+	}
+	if now := time.Now(); now.Sub(d.lastSweep) > time.Minute {
+		for id, exp := range d.revoked {
+			if !exp.IsZero() && now.After(exp) {
+				delete(d.revoked, id)
+			}
+		}
+		d.lastSweep = now
+	}
+	d.revoked[jti] = expiresAt
+}
+
+// IsRevoked implements Revoker.
+func (d *Denylist) IsRevoked(ctx context.Context, claims jwt.RegisteredClaims) (bool, error) {
+	if claims.ID != "" {
+		d.mu.Lock()
+		exp, ok := d.revoked[claims.ID]
+		d.mu.Unlock()
+		if ok && (exp.IsZero() || time.Now().Before(exp)) {
+			return true, nil
+		}
+	}
+
+	if d.IssuedBefore == nil {
+		return false, nil
+	}
+	before, err := d.IssuedBefore(ctx, claims.Subject)
+	if err != nil || before.IsZero() {
+		return false, err
+	}
+	// A token without iat cannot prove it was issued after the cutoff
+	return claims.IssuedAt == nil || claims.IssuedAt.Before(before.Truncate(time.Second)), nil
+}
+
+func withRevoker(ctx context.Context, revoker Revoker) context.Context {
+	return context.WithValue(ctx, revokerKey, revoker)
+}
+
+// isRevoked consults the Revoker of the request, if any.
+func isRevoked(ctx context.Context, claims jwt.RegisteredClaims) (bool, error) {
+	revoker, ok := ctx.Value(revokerKey).(Revoker)
+	if !ok {
+		return false, nil
+	}
+	return revoker.IsRevoked(ctx, claims)
+}
@@ -0,0 +1,158 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestGenerateJWT_EmitsJTI(t *testing.T) {
+	seen := map[string]bool{}
+	for range 3 {
+		token, _ := GenerateJWT("user123", "secret", time.Hour)
+		claims, err := ValidateToken[jwt.RegisteredClaims](context.Background(), token, TokenConfig{Secret: "secret"})
+		if err != nil || claims.ID == "" || seen[claims.ID] {
+			t.Fatalf("expected a unique jti, got %q, %v", claims.ID, err)
+		}
+		seen[claims.ID] = true
+	}
+
+	token, _ := GenerateToken(userClaims{TenantID: "acme"}, TokenConfig{Secret: "secret"})
+	if claims, _ := ValidateToken[userClaims](context.Background(), token, TokenConfig{Secret: "secret"}); claims.ID == "" {
+		t.Error("expected GenerateToken to add a jti")
+	}
+}
+
+func TestDenylist(t *testing.T) {
+	ctx := context.Background()
+	d := NewDenylist()
+	claims := NewRegisteredClaims("user123", time.Hour)
+
+	if revoked, _ := d.IsRevoked(ctx, claims); revoked {
+		t.Error("fresh token should not be revoked")
+	}
+	d.Revoke(claims.ID, claims.ExpiresAt.Time)
+	if revoked, _ := d.IsRevoked(ctx, claims); !revoked {
+		t.Error("expected token to be revoked")
+	}
+	if revoked, _ := d.IsRevoked(ctx, NewRegisteredClaims("user123", time.Hour)); revoked {
+		t.Error("other tokens should not be revoked")
+	}
+
+	// Entries are evicted once the token has expired
+	d.Revoke("expired", time.Now().Add(-time.Second))
+	d.lastSweep = time.Time{}
+	d.Revoke("trigger-sweep", time.Now().Add(time.Hour))
+	if _, ok := d.revoked["expired"]; ok {
+		t.Error("expected expired entry to be evicted")
+	}
+}
+
+func TestDenylist_ZeroValue(t *testing.T) {
+	ctx := context.Background()
+	var d Denylist
+	claims := jwt.RegisteredClaims{ID: "no-exp", Subject: "user123"}
+
+	if revoked, _ := d.IsRevoked(ctx, claims); revoked {
+		t.Error("fresh token should not be revoked")
+	}
+	// Tokens without exp are revoked with a zero expiry, and never evicted
+	d.Revoke(claims.ID, time.Time{})
+	d.lastSweep = time.Time{}
+	d.Revoke("trigger-sweep", time.Now().Add(time.Hour))
+	if revoked, _ := d.IsRevoked(ctx, claims); !revoked {
+		t.Error("expected token without exp to be revoked")
+	}
+}
+
+func TestDenylist_IssuedBefore(t *testing.T) {
+	ctx := context.Background()
+	cutoff := time.Now()
+	d := NewDenylist()
+	d.IssuedBefore = func(ctx context.Context, userID string) (time.Time, error) {
+		switch userID {
+		case "disabled":
+			return cutoff, nil
+		case "broken":
+			return time.Time{}, errors.New("database down")
+		}
+		return time.Time{}, nil
+	}
+
+	old := NewRegisteredClaims("disabled", time.Hour)
+	old.IssuedAt = jwt.NewNumericDate(cutoff.Add(-time.Minute))
+	if revoked, _ := d.IsRevoked(ctx, old); !revoked {
+		t.Error("expected token issued before the cutoff to be revoked")
+	}
+
+	fresh := NewRegisteredClaims("disabled", time.Hour)
+	fresh.IssuedAt = jwt.NewNumericDate(cutoff.Add(time.Minute))
+	if revoked, _ := d.IsRevoked(ctx, fresh); revoked {
+		t.Error("token issued after the cutoff should be valid")
+	}
+
+	noIAT := NewRegisteredClaims("disabled", time.Hour)
+	noIAT.IssuedAt = nil
+	if revoked, _ := d.IsRevoked(ctx, noIAT); !revoked {
+		t.Error("expected token without iat to be revoked once a cutoff is set")
+	}
+
+	if revoked, _ := d.IsRevoked(ctx, NewRegisteredClaims("active", time.Hour)); revoked {
+		t.Error("users without a cutoff should be unaffected")
+	}
+	if _, err := d.IsRevoked(ctx, NewRegisteredClaims("broken", time.Hour)); err == nil {
+		t.Error("expected IssuedBefore error to be returned")
+	}
+}
+
+func TestRequireAuth_Revoker(t *testing.T) {
+	secret := "test-secret"
+	denylist := NewDenylist()
+	routes := []Route{{
+		Method:     "GET",
+		Path:       "/me",
+		Handler:    func(ctx context.Context, r *http.Request) Response { return JSON(200, nil) },
+		Middleware: []Middleware{RequireAuth(secret)},
+	}}
+
+	var buf bytes.Buffer
+	router := testRouter(routes, Options{Revoker: denylist}, &buf)
+	request := func(token string) int {
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	token, _ := GenerateJWT("user123", secret, time.Hour)
+	if code := request(token); code != 200 {
+		t.Fatalf("expected 200 before revocation, got %d", code)
+	}
+
+	claims, _ := ValidateToken[jwt.RegisteredClaims](context.Background(), token, TokenConfig{Secret: secret})
+	denylist.Revoke(claims.ID, claims.ExpiresAt.Time)
+	if code := request(token); code != 401 {
+		t.Errorf("expected 401 after revocation, got %d", code)
+	}
+
+	// Revoker errors are logged server errors
+	denylist.IssuedBefore = func(ctx context.Context, userID string) (time.Time, error) {
+		return time.Time{}, errors.New("database down")
+	}
+	token, _ = GenerateJWT("user123", secret, time.Hour)
+	buf.Reset()
+	if code := request(token); code != 500 {
+		t.Errorf("expected 500 when the revoker fails, got %d", code)
+	}
+	if !strings.Contains(buf.String(), `"err":"database down"`) {
+		t.Errorf("expected the revoker error to be logged:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,491 @@
+package bedrock
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults used by Sessions when the SessionConfig fields are zero.
+const (
+	DefaultSessionCookie          = "session"
+	DefaultSessionIdleTimeout     = 30 * time.Minute
+	DefaultSessionAbsoluteTimeout = 24 * time.Hour
+)
+
+// sessionTouchInterval limits how often an unmodified session is saved
+// just to extend its idle timeout.
+const sessionTouchInterval = time.Minute
+
+const sessionKey contextKey = "session"
+
+// ErrSessionNotFound is returned by a SessionStore for unknown or expired sessions.
+var ErrSessionNotFound = errors.New("bedrock: session not found")
+
+// Session is the server-side state of a browser session. Handlers get it
+// with GetSession and may change it; Sessions saves it after the handler
+// returns.
+type Session struct {
+	ID        string            // random, regenerated on Login
+	UserID    string            // set by Login, empty for anonymous sessions
+	Values    map[string]string // application data
+	CreatedAt time.Time         // start of the session, or of the login
+	LastSeen  time.Time         // last request, for the idle timeout
+	ExpiresAt time.Time         // when the idle or absolute timeout ends it, for stores
+
+	dirty     bool
+	destroyed bool     // set by Logout
+	stale     bool     // the request's cookie named no valid session
+	oldIDs    []string // IDs replaced by Regenerate, to delete from the store
+}
+
+// Get returns a session value, or "" if unset.
+func (s *Session) Get(key string) string {
+	return s.Values[key]
+}
+
+// Set sets a session value.
+func (s *Session) Set(key, value string) {
+	if s.Values == nil {
+		s.Values = make(map[string]string)
+	}
+	s.Values[key] = value
+	s.dirty = true
+}
+
+// Delete removes a session value.
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+	s.dirty = true
+}
+
+// Login authenticates the session as userID. It regenerates the session ID,
+// so an ID planted before login (session fixation) is worthless, and
+// restarts the absolute timeout. A CSRF token kept in the session is
+// dropped too, and a new one issued on the next request.
+func (s *Session) Login(userID string) {
+	s.UserID = userID
+	s.CreatedAt = time.Now()
+	s.destroyed = false
+	delete(s.Values, csrfSessionKey)
+	s.Regenerate()
+}
+
+// Logout ends the session: it is deleted from the store and the cookie is
+// cleared.
+func (s *Session) Logout() {
+	s.UserID = ""
+	s.Values = nil
+	s.destroyed = true
+}
+
+// Regenerate gives the session a new ID, keeping its data. Call it whenever
+// the privileges of the session change.
+func (s *Session) Regenerate() {
+	if s.ID != "" {
+		s.oldIDs = append(s.oldIDs, s.ID)
+	}
+	s.ID = rand.Text()
+	s.dirty = true
+}
+
+// SessionStore persists sessions. The token is the cookie value: a session
+// ID for server-side stores, or the encrypted session itself for CookieStore.
+type SessionStore interface {
+	// Load returns the session for token, or ErrSessionNotFound.
+	Load(ctx context.Context, token string) (*Session, error)
+	// Save stores s until s.ExpiresAt and returns its token.
+	Save(ctx context.Context, s *Session) (string, error)
+	// Delete removes the session with id.
+	Delete(ctx context.Context, id string) error
+}
+
+// SessionConfig configures the Sessions middleware.
+type SessionConfig struct {
+	Store           SessionStore
+	CookieName      string        // optional; defaults to DefaultSessionCookie
+	IdleTimeout     time.Duration // optional; defaults to DefaultSessionIdleTimeout
+	AbsoluteTimeout time.Duration // optional; defaults to DefaultSessionAbsoluteTimeout
+
+	Path     string        // optional; cookie path, defaults to "/"
+	Domain   string        // optional; cookie domain, defaults to the request host
+	SameSite http.SameSite // optional; defaults to http.SameSiteLaxMode
+	// Insecure drops the Secure attribute so the cookie is sent over plain
+	// HTTP. Only for local development.
+	Insecure bool
+}
+
+// withDefaults returns a copy of cfg with every optional field filled in.
+func (cfg SessionConfig) withDefaults() SessionConfig {
+	if cfg.CookieName == "" {
+		cfg.CookieName = DefaultSessionCookie
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = DefaultSessionIdleTimeout
+	}
+	if cfg.AbsoluteTimeout == 0 {
+		cfg.AbsoluteTimeout = DefaultSessionAbsoluteTimeout
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+	return cfg
+}
+
+// Sessions creates middleware that loads the session from its cookie and
+// saves it after the handler. Sessions end after IdleTimeout without
+// requests, or AbsoluteTimeout after login, whichever comes first.
+//
+// If the session is logged in, its user ID is added to the context with
+// WithUserID, so GetUserID, RequireRole and friends work as with RequireAuth.
+//
+// Cookies are HttpOnly, Secure (unless Insecure is set) and SameSite=Lax by
+// default. Anonymous sessions are only saved, and a cookie only set, once
+// something is stored in them.
+//
+// Usage:
+//
+//	sessions := bedrock.Sessions(bedrock.SessionConfig{Store: bedrock.NewMemorySessionStore()})
+//	opts := bedrock.Options{Middleware: []bedrock.Middleware{sessions}}
+//
+//	func (a *MyApp) login(ctx context.Context, r *http.Request) bedrock.Response {
+//	    user, err := a.authenticate(ctx, r)
+//	    if err != nil {
+//	        return bedrock.Unauthorized("invalid credentials")
+//	    }
+//	    bedrock.GetSession(ctx).Login(user.ID)
+//	    return bedrock.JSON(200, user)
+//	}
+func Sessions(cfg SessionConfig) Middleware {
+	cfg = cfg.withDefaults()
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, r *http.Request) Response {
+			now := time.Now()
+			session, err := cfg.load(ctx, r, now)
+			if err != nil {
+				return InternalError().Wrap(err)
+			}
+
+			ctx = context.WithValue(ctx, sessionKey, session)
+			if session.UserID != "" {
+				ctx = WithUserID(ctx, session.UserID)
+			}
+
+			response := next(ctx, r)
+
+			cookie, err := cfg.commit(ctx, session, now)
+			if err != nil {
+				return InternalError().Wrap(err)
+			}
+			if cookie == nil {
+				return response
+			}
+			return cookieResponse{Response: response, cookie: cookie}
+		}
+	}
+}
+
+// load returns the request's session, or a new anonymous one if it has
+// none or it timed out.
+func (cfg SessionConfig) load(ctx context.Context, r *http.Request, now time.Time) (*Session, error) {
+	c, err := r.Cookie(cfg.CookieName)
+	if err != nil || c.Value == "" {
+		return &Session{CreatedAt: now, LastSeen: now}, nil
+	}
+
+	session, err := cfg.Store.Load(ctx, c.Value)
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		if now.Sub(session.LastSeen) <= cfg.IdleTimeout && now.Sub(session.CreatedAt) <= cfg.AbsoluteTimeout {
+			return session, nil
+		}
+		// Timed out
+		if err := cfg.Store.Delete(ctx, session.ID); err != nil {
+			return nil, err
+		}
+	}
+	return &Session{CreatedAt: now, LastSeen: now, stale: true}, nil
+}
+
+// commit saves or deletes the session as needed and returns the cookie to
+// set, if any.
+func (cfg SessionConfig) commit(ctx context.Context, s *Session, now time.Time) (*http.Cookie, error) {
+	for _, id := range s.oldIDs {
+		if err := cfg.Store.Delete(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.destroyed {
+		if s.ID != "" {
+			if err := cfg.Store.Delete(ctx, s.ID); err != nil {
+				return nil, err
+			}
+		}
+		return cfg.cookie("", -1), nil
+	}
+
+	// Save new data, and extend the idle timeout now and then
+	if !s.dirty && (s.ID == "" || now.Sub(s.LastSeen) < sessionTouchInterval) {
+		if s.stale {
+			return cfg.cookie("", -1), nil
+		}
+		return nil, nil
+	}
+	if s.ID == "" {
+		s.ID = rand.Text()
+	}
+	s.LastSeen = now
+	s.ExpiresAt = now.Add(cfg.IdleTimeout)
+	if absolute := s.CreatedAt.Add(cfg.AbsoluteTimeout); absolute.Before(s.ExpiresAt) {
+		s.ExpiresAt = absolute
+	}
+
+	token, err := cfg.Store.Save(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.cookie(token, int(time.Until(s.ExpiresAt).Seconds())), nil
+}
+
+func (cfg SessionConfig) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     cfg.CookieName,
+		Value:    value,
+		Path:     cfg.Path,
+		Domain:   cfg.Domain,
+		MaxAge:   maxAge,
+		Secure:   !cfg.Insecure,
+		HttpOnly: true,
+		SameSite: cfg.SameSite,
+	}
+}
+
+// cookieResponse wraps a Response and adds a cookie before writing it.
+type cookieResponse struct {
+	Response
+	cookie *http.Cookie
+}
+
+func (r cookieResponse) Write(ctx context.Context, w http.ResponseWriter) error {
+	http.SetCookie(w, r.cookie)
+	return r.Response.Write(ctx, w)
+}
+
+// GetSession returns the request's session. Outside the Sessions
+// middleware it returns a detached session whose changes are discarded.
+//
+// Example:
+//
+//	session := bedrock.GetSession(ctx)
+//	session.Set("theme", "dark")
+func GetSession(ctx context.Context) *Session {
+	if session, ok := sessionFromContext(ctx); ok {
+		return session
+	}
+	return &Session{}
+}
+
+// sessionFromContext returns the request's session, if the Sessions
+// middleware set one.
+func sessionFromContext(ctx context.Context) (*Session, bool) {
+	session, ok := ctx.Value(sessionKey).(*Session)
+	return session, ok
+}
+
+// MemorySessionStore is an in-memory SessionStore, for tests and
+// single-instance deployments. Sessions are lost on restart.
+type MemorySessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]Session
+	lastSweep time.Time
+}
+
+// NewMemorySessionStore returns an empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]Session)}
+}
+
+// Load implements SessionStore.
+func (m *MemorySessionStore) Load(ctx context.Context, token string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[token]
+	if !ok || time.Now().After(s.ExpiresAt) {
+		return nil, ErrSessionNotFound
+	}
+	s.Values = maps.Clone(s.Values)
+	return &s, nil
+}
+
+// Save implements SessionStore. Expired sessions are purged, at most once
+// a minute, as a side effect.
+func (m *MemorySessionStore) Save(ctx context.Context, s *Session) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now := time.Now(); now.Sub(m.lastSweep) > time.Minute {
+		for id, stored := range m.sessions {
+			if now.After(stored.ExpiresAt) {
+				delete(m.sessions, id)
+			}
+		}
+		m.lastSweep = now
+	}
+	m.sessions[s.ID] = Session{
+		ID:        s.ID,
+		UserID:    s.UserID,
+		Values:    maps.Clone(s.Values),
+		CreatedAt: s.CreatedAt,
+		LastSeen:  s.LastSeen,
+		ExpiresAt: s.ExpiresAt,
+	}
+	return s.ID, nil
+}
+
+// Delete implements SessionStore.
+func (m *MemorySessionStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+// maxCookieSize is the largest cookie value browsers reliably accept.
+const maxCookieSize = 4000
+
+// CookieStore is a SessionStore that keeps the whole session in the cookie,
+// encrypted and authenticated with AES-256-GCM, so no server-side storage
+// is needed. Clients can neither read nor alter it.
+//
+// Sessions cannot be revoked server-side: Logout clears the cookie, but a
+// copy of it stays valid until its timeouts. Sessions must also fit in a
+// cookie, about 4KB.
+//
+// Example:
+//
+//	store, err := bedrock.NewCookieStore(cfg.SessionKey) // 32 random bytes
+type CookieStore struct {
+	aeads []cipher.AEAD
+}
+
+// NewCookieStore returns a CookieStore for the given 32-byte keys. The
+// first key encrypts; all of them decrypt, so keys can be rotated by
+// prepending a new one.
+func NewCookieStore(keys ...[]byte) (*CookieStore, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("bedrock: CookieStore needs a key")
+	}
+	store := &CookieStore{}
+	for _, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("bedrock: CookieStore keys must be 32 bytes, got %d", len(key))
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		store.aeads = append(store.aeads, aead)
+	}
+	return store, nil
+}
+
+// newGCM returns an AES-GCM cipher for key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// cookieSession is the encrypted form of a Session.
+type cookieSession struct {
+	ID        string            `json:"i"`
+	UserID    string            `json:"u,omitempty"`
+	Values    map[string]string `json:"v,omitempty"`
+	CreatedAt int64             `json:"c"`
+	LastSeen  int64             `json:"l"`
+	ExpiresAt int64             `json:"e"`
+}
+
+// Load implements SessionStore.
+func (c *CookieStore) Load(ctx context.Context, token string) (*Session, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrSessionNotFound
+	}
+	for _, aead := range c.aeads {
+		if len(sealed) < aead.NonceSize() {
+			break
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, ciphertext, []byte("bedrock-session"))
+		if err != nil {
+			continue
+		}
+
+		var cs cookieSession
+		if err := json.Unmarshal(plaintext, &cs); err != nil {
+			return nil, ErrSessionNotFound
+		}
+		s := &Session{
+			ID:        cs.ID,
+			UserID:    cs.UserID,
+			Values:    cs.Values,
+			CreatedAt: time.Unix(cs.CreatedAt, 0),
+			LastSeen:  time.Unix(cs.LastSeen, 0),
+			ExpiresAt: time.Unix(cs.ExpiresAt, 0),
+		}
+		if time.Now().After(s.ExpiresAt) {
+			return nil, ErrSessionNotFound
+		}
+		return s, nil
+	}
+	return nil, ErrSessionNotFound
+}
+
+// Save implements SessionStore.
+func (c *CookieStore) Save(ctx context.Context, s *Session) (string, error) {
+	plaintext, err := json.Marshal(cookieSession{
+		ID:        s.ID,
+		UserID:    s.UserID,
+		Values:    s.Values,
+		CreatedAt: s.CreatedAt.Unix(),
+		LastSeen:  s.LastSeen.Unix(),
+		ExpiresAt: s.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	aead := c.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	token := base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, []byte("bedrock-session")))
+	if len(token) > maxCookieSize {
+		return "", fmt.Errorf("bedrock: session too large for a cookie (%d bytes)", len(token))
+	}
+	return token, nil
+}
+
+// Delete implements SessionStore. The session lives in the cookie, which
+// Sessions clears, so there is nothing to delete.
+func (c *CookieStore) Delete(ctx context.Context, id string) error {
+	return nil
+}
@@ -0,0 +1,262 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sessionClient sends requests through handler, keeping the session cookie
+// like a browser would.
+type sessionClient struct {
+	t       *testing.T
+	handler Handler
+	cookie  *http.Cookie
+}
+
+func (c *sessionClient) do(path string) *httptest.ResponseRecorder {
+	c.t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	if c.cookie != nil {
+		req.AddCookie(c.cookie)
+	}
+	w := httptest.NewRecorder()
+	c.handler(context.Background(), req).Write(context.Background(), w)
+
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			c.cookie = nil
+		} else {
+			c.cookie = cookie
+		}
+	}
+	return w
+}
+
+// sessionHandler logs in on /login, logs out on /logout, stores a value on
+// /set and otherwise echoes the user ID and stored value.
+func sessionHandler(cfg SessionConfig) Handler {
+	return Chain(func(ctx context.Context, r *http.Request) Response {
+		session := GetSession(ctx)
+		switch r.URL.Path {
+		case "/login":
+			session.Login("user123")
+		case "/logout":
+			session.Logout()
+		case "/set":
+			session.Set("theme", "dark")
+		}
+		userID, _ := GetUserID(ctx)
+		return JSON(200, map[string]string{"user_id": userID, "theme": session.Get("theme")})
+	}, Sessions(cfg))
+}
+
+func testSessionStores(t *testing.T) map[string]SessionStore {
+	cookies, err := NewCookieStore(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]SessionStore{"memory": NewMemorySessionStore(), "cookie": cookies}
+}
+
+func TestSessions_LoginAndLogout(t *testing.T) {
+	for name, store := range testSessionStores(t) {
+		client := &sessionClient{t: t, handler: sessionHandler(SessionConfig{Store: store})}
+
+		// Anonymous requests do not create sessions
+		if w := client.do("/"); len(w.Result().Cookies()) != 0 {
+			t.Errorf("%s: expected no cookie for an untouched session", name)
+		}
+
+		client.do("/set")
+		anonymous := client.cookie
+		if anonymous == nil {
+			t.Fatalf("%s: expected a cookie once the session holds data", name)
+		}
+		if !anonymous.HttpOnly || !anonymous.Secure || anonymous.SameSite != http.SameSiteLaxMode || anonymous.Path != "/" {
+			t.Errorf("%s: unexpected cookie attributes: %+v", name, anonymous)
+		}
+
+		client.do("/login")
+		if client.cookie == nil || client.cookie.Value == anonymous.Value {
+			t.Fatalf("%s: expected the session to be regenerated on login", name)
+		}
+		body := decodeBody(t, client.do("/"))
+		if body["user_id"] != "user123" || body["theme"] != "dark" {
+			t.Errorf("%s: expected user and data to survive login, got %v", name, body)
+		}
+
+		client.do("/logout")
+		if client.cookie != nil {
+			t.Errorf("%s: expected the cookie to be cleared on logout", name)
+		}
+		if body := decodeBody(t, client.do("/")); body["user_id"] != "" {
+			t.Errorf("%s: expected to be logged out, got %v", name, body)
+		}
+	}
+}
+
+func TestSessions_FixationProtection(t *testing.T) {
+	store := NewMemorySessionStore()
+	client := &sessionClient{t: t, handler: sessionHandler(SessionConfig{Store: store})}
+
+	client.do("/set")
+	planted := client.cookie // e.g. set by an attacker before the victim logs in
+	client.do("/login")
+
+	attacker := &sessionClient{t: t, handler: client.handler, cookie: planted}
+	if body := decodeBody(t, attacker.do("/")); body["user_id"] != "" {
+		t.Errorf("pre-login session ID must not be authenticated, got %v", body)
+	}
+}
+
+func TestSessions_Timeouts(t *testing.T) {
+	store := NewMemorySessionStore()
+	client := &sessionClient{t: t, handler: sessionHandler(SessionConfig{Store: store})}
+	client.do("/login")
+	id := client.cookie.Value
+
+	age := func(idle, total time.Duration) {
+		store.mu.Lock()
+		s := store.sessions[id]
+		s.LastSeen = time.Now().Add(-idle)
+		s.CreatedAt = time.Now().Add(-total)
+		store.sessions[id] = s
+		store.mu.Unlock()
+	}
+
+	// Requests after the touch interval extend the idle timeout
+	age(2*time.Minute, time.Hour)
+	if w := client.do("/"); len(w.Result().Cookies()) != 1 {
+		t.Error("expected the cookie to be refreshed")
+	}
+	if seen := store.sessions[id].LastSeen; time.Since(seen) > time.Second {
+		t.Errorf("expected LastSeen to be updated, got %v", seen)
+	}
+
+	age(31*time.Minute, time.Hour)
+	if body := decodeBody(t, client.do("/")); body["user_id"] != "" {
+		t.Errorf("expected idle session to be expired, got %v", body)
+	}
+	if client.cookie != nil {
+		t.Error("expected the stale cookie to be cleared")
+	}
+
+	client.do("/login")
+	id = client.cookie.Value
+	age(time.Minute, 25*time.Hour)
+	if body := decodeBody(t, client.do("/")); body["user_id"] != "" {
+		t.Errorf("expected session past the absolute timeout to be expired, got %v", body)
+	}
+}
+
+func TestSessions_Options(t *testing.T) {
+	client := &sessionClient{t: t, handler: sessionHandler(SessionConfig{
+		Store:      NewMemorySessionStore(),
+		CookieName: "sid",
+		Path:       "/app",
+		SameSite:   http.SameSiteStrictMode,
+		Insecure:   true,
+	})}
+	client.do("/login")
+
+	c := client.cookie
+	if c == nil || c.Name != "sid" || c.Path != "/app" || c.SameSite != http.SameSiteStrictMode || c.Secure || !c.HttpOnly {
+		t.Errorf("unexpected cookie: %+v", c)
+	}
+	if c.MaxAge <= 0 || c.MaxAge > int(DefaultSessionIdleTimeout.Seconds()) {
+		t.Errorf("expected MaxAge up to the idle timeout, got %d", c.MaxAge)
+	}
+}
+
+// failingSessionStore is a SessionStore whose backend is down.
+type failingSessionStore struct{}
+
+func (failingSessionStore) Load(ctx context.Context, token string) (*Session, error) {
+	return nil, errors.New("load failed")
+}
+
+func (failingSessionStore) Save(ctx context.Context, s *Session) (string, error) {
+	return "", errors.New("save failed")
+}
+
+func (failingSessionStore) Delete(ctx context.Context, id string) error {
+	return errors.New("delete failed")
+}
+
+func TestSessions_StoreErrors(t *testing.T) {
+	handler := sessionHandler(SessionConfig{Store: failingSessionStore{}})
+	request := func(path string, cookie *http.Cookie) (int, string) {
+		var buf bytes.Buffer
+		ctx := WithLogger(context.Background(), slog.New(slog.NewJSONHandler(&buf, nil)))
+		req := httptest.NewRequest("GET", path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler(ctx, req).Write(ctx, w)
+		return w.Code, buf.String()
+	}
+
+	code, logs := request("/", &http.Cookie{Name: DefaultSessionCookie, Value: "abc"})
+	if code != 500 || !strings.Contains(logs, `"err":"load failed"`) {
+		t.Errorf("expected a logged 500 when loading fails, got %d:\n%s", code, logs)
+	}
+	code, logs = request("/set", nil)
+	if code != 500 || !strings.Contains(logs, `"err":"save failed"`) {
+		t.Errorf("expected a logged 500 when saving fails, got %d:\n%s", code, logs)
+	}
+}
+
+func TestCookieStore(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	old, _ := NewCookieStore(oldKey)
+	ctx := context.Background()
+
+	now := time.Now()
+	session := &Session{ID: "abc", UserID: "user123", Values: map[string]string{"k": "v"}, CreatedAt: now, LastSeen: now, ExpiresAt: now.Add(time.Hour)}
+	token, err := old.Save(ctx, session)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if strings.Contains(token, "user123") {
+		t.Error("cookie is not encrypted")
+	}
+
+	// After rotation, cookies encrypted with the old key still load
+	rotated, _ := NewCookieStore(newKey, oldKey)
+	loaded, err := rotated.Load(ctx, token)
+	if err != nil || loaded.UserID != "user123" || loaded.Get("k") != "v" {
+		t.Fatalf("got %+v, %v", loaded, err)
+	}
+
+	// Tampered or foreign cookies are rejected
+	tampered := token[:len(token)-2] + "AA"
+	if _, err := rotated.Load(ctx, tampered); err != ErrSessionNotFound {
+		t.Errorf("expected tampered cookie to be rejected, got %v", err)
+	}
+	other, _ := NewCookieStore(bytes.Repeat([]byte{3}, 32))
+	if _, err := other.Load(ctx, token); err != ErrSessionNotFound {
+		t.Errorf("expected cookie from another key to be rejected, got %v", err)
+	}
+
+	session.ExpiresAt = now.Add(-time.Second)
+	expired, _ := old.Save(ctx, session)
+	if _, err := old.Load(ctx, expired); err != ErrSessionNotFound {
+		t.Errorf("expected expired cookie to be rejected, got %v", err)
+	}
+
+	session.Values["big"] = strings.Repeat("x", 5000)
+	if _, err := old.Save(ctx, session); err == nil {
+		t.Error("expected error for a session too large for a cookie")
+	}
+	if _, err := NewCookieStore([]byte("short")); err == nil {
+		t.Error("expected error for a short key")
+	}
+}
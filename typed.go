@@ -0,0 +1,53 @@
+package bedrock
+
+import (
+	"context"
+	"net/http"
+)
+
+// Typed adapts a strongly typed function into a Handler.
+//
+// The request is bound into a new Req with Bind (JSON body, then path, query
+// and header tags) and checked with Validate. Binding failures return 400 and
+// validation failures 422, both with field-level details and without calling
+// fn. A non-nil error from fn is converted with FromError.
+// Otherwise resp is written as JSON with status 200, unless Resp itself
+// implements Response, in which case it is written as is (use this for
+// other status codes, e.g. return bedrock.JSON(201, booking)).
+//
+// The result is a plain Handler, so it works with Route.Middleware and Chain.
+//
+// Example:
+//
+//	type GetBooking struct {
+//	    ID string `path:"id"`
+//	}
+//
+//	func (a *App) getBooking(ctx context.Context, req GetBooking) (Booking, error) {
+//	    return a.store.Get(ctx, req.ID)
+//	}
+//
+//	routes := []bedrock.Route{
+//	    {Method: "GET", Path: "/bookings/{id}", Handler: bedrock.Typed(a.getBooking)},
+//	}
+func Typed[Req, Resp any](fn func(ctx context.Context, req Req) (Resp, error)) Handler {
+	return func(ctx context.Context, r *http.Request) Response {
+		var req Req
+		if err := Bind(r, &req); err != nil {
+			return FromError(err)
+		}
+		if err := Validate(&req); err != nil {
+			return FromError(err)
+		}
+
+		resp, err := fn(ctx, req)
+		if err != nil {
+			return FromError(err)
+		}
+
+		if response, ok := any(resp).(Response); ok {
+			return response
+		}
+		return JSON(http.StatusOK, resp)
+	}
+}
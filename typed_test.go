@@ -0,0 +1,222 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type createBookingRequest struct {
+	RoomID string    `path:"roomID" json:"-"`
+	Notify bool      `query:"notify" json:"-"`
+	Tags   []string  `query:"tag" json:"-"`
+	Tenant *string   `header:"X-Tenant" json:"-"`
+	Guests int       `json:"guests"`
+	Start  time.Time `json:"start"`
+}
+
+type booking struct {
+	RoomID string   `json:"room_id"`
+	Guests int      `json:"guests"`
+	Notify bool     `json:"notify"`
+	Tags   []string `json:"tags"`
+	Tenant string   `json:"tenant"`
+}
+
+func TestTyped_BindsAllSources(t *testing.T) {
+	handler := Typed(func(ctx context.Context, req createBookingRequest) (booking, error) {
+		return booking{
+			RoomID: req.RoomID,
+			Guests: req.Guests,
+			Notify: req.Notify,
+			Tags:   req.Tags,
+			Tenant: *req.Tenant,
+		}, nil
+	})
+
+	var buf bytes.Buffer
+	router := testRouter([]Route{{Method: "POST", Path: "/rooms/{roomID}/bookings", Handler: handler}}, Options{}, &buf)
+
+	body := strings.NewReader(`{"guests": 3, "start": "2026-01-02T15:04:05Z"}`)
+	req := httptest.NewRequest("POST", "/rooms/r42/bookings?notify=true&tag=a&tag=b", body)
+	req.Header.Set("X-Tenant", "acme")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	got := decodeBody(t, w)
+	if got["room_id"] != "r42" || got["guests"] != float64(3) || got["notify"] != true || got["tenant"] != "acme" {
+		t.Errorf("unexpected response: %v", got)
+	}
+	if tags, _ := got["tags"].([]any); len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Errorf("unexpected tags: %v", got["tags"])
+	}
+}
+
+func TestTyped_BindErrorsReturnFieldDetails(t *testing.T) {
+	called := false
+	handler := Typed(func(ctx context.Context, req createBookingRequest) (booking, error) {
+		called = true
+		return booking{}, nil
+	})
+
+	body := strings.NewReader(`{"guests": "three"}`)
+	req := httptest.NewRequest("POST", "/rooms/r1/bookings?notify=maybe", body)
+	w := httptest.NewRecorder()
+	handler(context.Background(), req).Write(context.Background(), w)
+
+	if called {
+		t.Error("handler should not run when binding fails")
+	}
+	if w.Code != 400 {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+
+	got := decodeBody(t, w)
+	details, _ := got["details"].([]any)
+	fields := map[string]string{}
+	for _, d := range details {
+		fe := d.(map[string]any)
+		fields[fe["field"].(string)] = fe["message"].(string)
+	}
+	if fields["body.guests"] != "must be an integer" {
+		t.Errorf("expected body.guests error, got %v", fields)
+	}
+	if fields["query.notify"] != "must be a boolean" {
+		t.Errorf("expected query.notify error, got %v", fields)
+	}
+}
+
+func TestTyped_MalformedJSON(t *testing.T) {
+	handler := Typed(func(ctx context.Context, req createBookingRequest) (booking, error) {
+		return booking{}, nil
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"guests":`))
+	handler(context.Background(), req).Write(context.Background(), w)
+
+	if w.Code != 400 {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestTyped_ErrorIsMapped(t *testing.T) {
+	errMissing := errors.New("room missing")
+	mapper := NewErrorMapper()
+	mapper.Register(errMissing, http.StatusNotFound, "", "room not found")
+
+	handler := Typed(func(ctx context.Context, req struct{}) (booking, error) {
+		return booking{}, errMissing
+	})
+
+	ctx := withErrorConfig(context.Background(), errorConfig{mapper: mapper})
+	w := httptest.NewRecorder()
+	handler(ctx, httptest.NewRequest("GET", "/", nil)).Write(ctx, w)
+
+	if w.Code != 404 {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestTyped_ResponsePassthrough(t *testing.T) {
+	handler := Typed(func(ctx context.Context, req struct{}) (Response, error) {
+		return JSON(http.StatusCreated, map[string]string{"id": "1"}), nil
+	})
+
+	w := httptest.NewRecorder()
+	handler(context.Background(), httptest.NewRequest("POST", "/", nil)).Write(context.Background(), w)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected 201, got %d", w.Code)
+	}
+}
+
+func TestTyped_WorksWithMiddleware(t *testing.T) {
+	handler := Typed(func(ctx context.Context, req struct{}) (map[string]string, error) {
+		userID, _ := GetUserID(ctx)
+		return map[string]string{"user": userID}, nil
+	})
+	protected := Chain(handler, RequireAuth("secret"))
+
+	token, _ := GenerateJWT("user123", "secret", time.Hour)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	protected(context.Background(), req).Write(context.Background(), w)
+
+	if got := decodeBody(t, w); got["user"] != "user123" {
+		t.Errorf("unexpected response: %v", got)
+	}
+}
+
+func TestBind_RequiresPointer(t *testing.T) {
+	var req struct{}
+	if err := Bind(httptest.NewRequest("GET", "/", nil), req); err == nil {
+		t.Error("expected error for non-pointer")
+	}
+}
+
+func TestBind_BodyCannotSetTaggedFields(t *testing.T) {
+	type request struct {
+		ID     string `path:"id"`
+		Page   int    `query:"page"`
+		Tenant string `header:"X-Tenant"`
+		Name   string `json:"name"`
+	}
+	body := `{"name": "a", "ID": "zzz", "Page": 9, "Tenant": "evil"}`
+
+	// Absent sources leave the fields as they were
+	req := request{Page: 1}
+	if err := Bind(httptest.NewRequest("POST", "/", strings.NewReader(body)), &req); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	if req != (request{Page: 1, Name: "a"}) {
+		t.Errorf("expected the body to set only name, got %+v", req)
+	}
+
+	// Present sources win over the body
+	r := mux.SetURLVars(httptest.NewRequest("POST", "/?page=2", strings.NewReader(body)), map[string]string{"id": "b1"})
+	r.Header.Set("X-Tenant", "acme")
+	req = request{}
+	if err := Bind(r, &req); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+	if req != (request{ID: "b1", Page: 2, Tenant: "acme", Name: "a"}) {
+		t.Errorf("unexpected binding: %+v", req)
+	}
+}
+
+func TestBind_StrictBody(t *testing.T) {
+	var req createBookingRequest
+	for body, field := range map[string]string{
+		`{"guests": 1, "admin": true}`: "body.admin",
+		`{"guests": 1} {"guests": 2}`:  "body",
+	} {
+		err := Bind(httptest.NewRequest("POST", "/", strings.NewReader(body)), &req)
+		if fields := fieldErrors(t, err); len(fields) != 1 || fields[field].Field != field {
+			t.Errorf("%s: expected a %s error, got %v", body, field, fields)
+		}
+	}
+
+	large := `{"guests": 1, "start": "` + strings.Repeat(" ", DefaultMaxBodyBytes) + `"}`
+	var httpErr *HTTPError
+	err := Bind(httptest.NewRequest("POST", "/", strings.NewReader(large)), &req)
+	if !errors.As(err, &httpErr) || httpErr.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %v", err)
+	}
+
+	// An empty body is still fine
+	if err := Bind(httptest.NewRequest("POST", "/", strings.NewReader("")), &req); err != nil {
+		t.Errorf("expected an empty body to be accepted, got %v", err)
+	}
+}
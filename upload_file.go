@@ -6,8 +6,6 @@ import (
 	"net/http"
 )
 
-// ... existing helpers (DecodeJSON, PathParam, QueryParam) ...
-
 // UploadedFile represents a file from a multipart form
 type UploadedFile struct {
 	File     multipart.File
@@ -0,0 +1,339 @@
+package bedrock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxBodyBytes is the body size limit used by DecodeAndValidate when maxBytes is 0.
+const DefaultMaxBodyBytes = 1 << 20 // 1MB
+
+// DecodeAndValidate strictly decodes a JSON body into v and validates it.
+//
+// Unlike DecodeJSON it rejects bodies larger than maxBytes (DefaultMaxBodyBytes
+// if 0) with 413, unknown fields and trailing data after the JSON value with 400,
+// and fields failing their `validate` tags with 422 (see Validate).
+// All errors are *HTTPErrors, so they can be returned with FromError.
+//
+// Example:
+//
+//	type SignupRequest struct {
+//	    Email string `json:"email" validate:"required,email"`
+//	    Name  string `json:"name" validate:"required,min=3,max=64"`
+//	    Plan  string `json:"plan" validate:"oneof=free pro"`
+//	}
+//
+//	var req SignupRequest
+//	if err := bedrock.DecodeAndValidate(r, &req, 0); err != nil {
+//	    return bedrock.FromError(err)
+//	}
+func DecodeAndValidate(r *http.Request, v any, maxBytes int64) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return BadRequest("invalid request").WithDetails([]FieldError{{Field: "body", Message: "is required"}})
+	}
+	if err := decodeStrict(r, v, maxBytes); err != nil {
+		if errors.Is(err, io.EOF) {
+			return BadRequest("invalid request").WithDetails([]FieldError{jsonFieldError(err)})
+		}
+		return err
+	}
+	return Validate(v)
+}
+
+// decodeStrict decodes the JSON body of r into v. It returns a 413
+// *HTTPError for bodies larger than maxBytes (DefaultMaxBodyBytes if 0), a
+// 400 *HTTPError for invalid JSON, unknown fields and trailing data, and
+// io.EOF for an empty body.
+func decodeStrict(r *http.Request, v any, maxBytes int64) error {
+	if maxBytes == 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return NewHTTPError(http.StatusRequestEntityTooLarge, "", fmt.Sprintf("request body must not exceed %d bytes", maxBytes))
+		}
+		if err == io.EOF {
+			return err
+		}
+		return BadRequest("invalid request").WithDetails([]FieldError{decodeFieldError(err)})
+	}
+
+	// Anything but whitespace after the first value is rejected
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return NewHTTPError(http.StatusRequestEntityTooLarge, "", fmt.Sprintf("request body must not exceed %d bytes", maxBytes))
+		}
+		return BadRequest("invalid request").WithDetails([]FieldError{{Field: "body", Message: "must contain a single JSON value"}})
+	}
+	return nil
+}
+
+// decodeFieldError describes a strict decoding error as a FieldError.
+func decodeFieldError(err error) FieldError {
+	// encoding/json reports unknown fields only as text: `json: unknown field "x"`
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return FieldError{Field: "body." + strings.Trim(name, `"`), Rule: "unknown", Message: "is not allowed"}
+	}
+	return jsonFieldError(err)
+}
+
+// Validate checks v, a struct or pointer to struct, against its `validate` tags.
+// Nested structs, pointers to structs and slices of structs are validated too.
+//
+// Supported rules, comma separated:
+//
+//	required   value must not be the zero value (non-empty string, slice or map; non-nil pointer)
+//	min=N      strings: at least N characters; slices and maps: at least N items; numbers: >= N
+//	max=N      strings: at most N characters; slices and maps: at most N items; numbers: <= N
+//	len=N      strings: exactly N characters; slices and maps: exactly N items
+//	email      a valid email address
+//	oneof=a b  one of the space-separated values
+//	omitempty  skip the remaining rules for the zero value
+//
+// Rules other than required are skipped for empty strings, slices and maps
+// and nil pointers, so optional fields only need to be valid when present.
+// Numbers are checked even when 0, so min=1 rejects 0, unless the tag
+// starts with omitempty.
+//
+// On failure Validate returns a 422 *HTTPError whose Details lists every
+// failing field as a FieldError with its path (by JSON name) and rule.
+func Validate(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fieldErrs []FieldError
+	if err := validateStruct(rv, "", &fieldErrs); err != nil {
+		return err
+	}
+	if len(fieldErrs) > 0 {
+		return UnprocessableEntity("validation failed").WithDetails(fieldErrs)
+	}
+	return nil
+}
+
+// validateStruct appends a FieldError for every failing rule in v.
+// It returns an error only for malformed tags.
+func validateStruct(v reflect.Value, prefix string, fieldErrs *[]FieldError) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		field := v.Field(i)
+
+		if sf.Anonymous && field.Kind() == reflect.Struct {
+			if err := validateStruct(field, prefix, fieldErrs); err != nil {
+				return err
+			}
+			continue
+		}
+
+		path := fieldPath(sf, prefix)
+		if path == "" {
+			continue
+		}
+
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			if err := validateField(field, path, tag, fieldErrs); err != nil {
+				return fmt.Errorf("bedrock: %s.%s: %w", t.Name(), sf.Name, err)
+			}
+		}
+
+		if err := validateNested(field, path, fieldErrs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldPath returns the path used to report errors for sf, mirroring Bind:
+// "query.limit" for bound fields, "body.address.city" for JSON fields.
+// Fields hidden from JSON and not bound return "".
+func fieldPath(sf reflect.StructField, prefix string) string {
+	for _, source := range []string{"path", "query", "header"} {
+		if name := sf.Tag.Get(source); name != "" {
+			return source + "." + name
+		}
+	}
+
+	name := sf.Name
+	if tag := sf.Tag.Get("json"); tag != "" {
+		tagName, _, _ := strings.Cut(tag, ",")
+		if tagName == "-" {
+			return ""
+		}
+		if tagName != "" {
+			name = tagName
+		}
+	}
+	if prefix == "" {
+		prefix = "body"
+	}
+	return prefix + "." + name
+}
+
+// validateNested descends into struct, pointer-to-struct and slice-of-struct values.
+func validateNested(field reflect.Value, path string, fieldErrs *[]FieldError) error {
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+
+	switch field.Kind() {
+	case reflect.Struct:
+		if implementsTextUnmarshaler(field) {
+			return nil // e.g. time.Time is a leaf value
+		}
+		return validateStruct(field, path, fieldErrs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < field.Len(); i++ {
+			if err := validateNested(field.Index(i), fmt.Sprintf("%s[%d]", path, i), fieldErrs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateField applies each rule in tag to field, stopping at the first failure.
+// Zero values skip the rules after required, except numbers, whose zero is a
+// value like any other unless the tag says omitempty.
+func validateField(field reflect.Value, path, tag string, fieldErrs *[]FieldError) error {
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+		switch name {
+		case "required":
+			if field.IsZero() {
+				*fieldErrs = append(*fieldErrs, FieldError{Field: path, Rule: name, Message: "is required"})
+				return nil
+			}
+			continue
+		case "omitempty":
+			if field.IsZero() {
+				return nil
+			}
+			continue
+		}
+		if field.IsZero() && !isNumber(field) {
+			return nil
+		}
+
+		message, err := checkRule(field, name, param)
+		if err != nil {
+			return err
+		}
+		if message != "" {
+			*fieldErrs = append(*fieldErrs, FieldError{Field: path, Rule: name, Message: message})
+			return nil
+		}
+	}
+	return nil
+}
+
+// isNumber reports whether field holds an integer or floating-point number.
+func isNumber(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// checkRule returns a failure message, or "" if field satisfies the rule.
+func checkRule(field reflect.Value, name, param string) (string, error) {
+	for field.Kind() == reflect.Ptr {
+		field = field.Elem()
+	}
+
+	switch name {
+	case "min", "max", "len":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s parameter %q", name, param)
+		}
+		return checkBound(field, name, n)
+
+	case "email":
+		if field.Kind() != reflect.String {
+			return "", errors.New("email rule requires a string field")
+		}
+		addr, err := mail.ParseAddress(field.String())
+		if err != nil || addr.Address != field.String() {
+			return "must be a valid email address", nil
+		}
+		return "", nil
+
+	case "oneof":
+		options := strings.Fields(param)
+		value := fmt.Sprint(field.Interface())
+		for _, option := range options {
+			if value == option {
+				return "", nil
+			}
+		}
+		return "must be one of: " + strings.Join(options, ", "), nil
+	}
+
+	return "", fmt.Errorf("unknown validation rule %q", name)
+}
+
+// checkBound applies min, max and len to lengths or numeric values.
+func checkBound(field reflect.Value, rule string, bound float64) (string, error) {
+	var size float64
+	var unit string
+
+	switch field.Kind() {
+	case reflect.String:
+		size, unit = float64(utf8.RuneCountInString(field.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		size, unit = float64(field.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(field.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(field.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = field.Float()
+	default:
+		return "", fmt.Errorf("%s rule not supported on %s", rule, field.Type())
+	}
+
+	bs := strconv.FormatFloat(bound, 'f', -1, 64)
+	switch {
+	case rule == "min" && size < bound:
+		return "must be at least " + bs + unit, nil
+	case rule == "max" && size > bound:
+		return "must be at most " + bs + unit, nil
+	case rule == "len" && unit == "":
+		return "", errors.New("len rule requires a string, slice or map field")
+	case rule == "len" && size != bound:
+		return "must be exactly " + bs + unit, nil
+	}
+	return "", nil
+}
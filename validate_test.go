@@ -0,0 +1,223 @@
+package bedrock
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type signupRequest struct {
+	Email     string    `json:"email" validate:"required,email"`
+	Name      string    `json:"name" validate:"required,min=3,max=64"`
+	Plan      string    `json:"plan" validate:"oneof=free pro"`
+	Age       int       `json:"age" validate:"omitempty,min=18"`
+	Nickname  *string   `json:"nickname,omitempty" validate:"max=5"`
+	Address   address   `json:"address"`
+	Addresses []address `json:"addresses" validate:"max=2"`
+	Limit     int       `query:"limit" json:"-" validate:"max=100"`
+}
+
+// fieldErrors returns the FieldErrors of a validation or binding error keyed by field.
+func fieldErrors(t *testing.T, err error) map[string]FieldError {
+	t.Helper()
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("expected *HTTPError, got %T: %v", err, err)
+	}
+	details, ok := httpErr.Details.([]FieldError)
+	if !ok {
+		t.Fatalf("expected []FieldError details, got %T", httpErr.Details)
+	}
+	fields := make(map[string]FieldError, len(details))
+	for _, fe := range details {
+		fields[fe.Field] = fe
+	}
+	return fields
+}
+
+func TestValidate_Valid(t *testing.T) {
+	req := signupRequest{
+		Email:   "ada@example.com",
+		Name:    "Ada",
+		Plan:    "pro",
+		Age:     36,
+		Address: address{City: "London"},
+	}
+	if err := Validate(&req); err != nil {
+		t.Errorf("expected valid, got %v", err)
+	}
+}
+
+func TestValidate_ReportsEveryFailingField(t *testing.T) {
+	long := "toolong"
+	req := signupRequest{
+		Email:     "not-an-email",
+		Name:      "Al",
+		Plan:      "enterprise",
+		Age:       12,
+		Nickname:  &long,
+		Addresses: []address{{City: "Paris"}, {}},
+		Limit:     500,
+	}
+
+	err := Validate(&req)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 HTTPError, got %v", err)
+	}
+
+	want := map[string]string{
+		"body.email":             "email",
+		"body.name":              "min",
+		"body.plan":              "oneof",
+		"body.age":               "min",
+		"body.nickname":          "max",
+		"body.address.city":      "required",
+		"body.addresses[1].city": "required",
+		"query.limit":            "max",
+	}
+	fields := fieldErrors(t, err)
+	if len(fields) != len(want) {
+		t.Errorf("expected %d field errors, got %v", len(want), fields)
+	}
+	for field, rule := range want {
+		if fields[field].Rule != rule {
+			t.Errorf("%s: got rule %q, want %q", field, fields[field].Rule, rule)
+		}
+	}
+	if msg := fields["body.name"].Message; msg != "must be at least 3 characters" {
+		t.Errorf("unexpected min message: %q", msg)
+	}
+	if msg := fields["body.plan"].Message; msg != "must be one of: free, pro" {
+		t.Errorf("unexpected oneof message: %q", msg)
+	}
+}
+
+func TestValidate_OptionalFieldsSkipRules(t *testing.T) {
+	req := struct {
+		Website string `json:"website" validate:"min=10"`
+	}{}
+	if err := Validate(&req); err != nil {
+		t.Errorf("empty optional field should be valid, got %v", err)
+	}
+}
+
+func TestValidate_ZeroNumbers(t *testing.T) {
+	req := struct {
+		Guests int    `json:"guests" validate:"min=1"`
+		Rating int    `json:"rating" validate:"oneof=1 2 3"`
+		Age    int    `json:"age" validate:"omitempty,min=18"`
+		Name   string `json:"name" validate:"omitempty,min=3"`
+	}{}
+
+	fields := fieldErrors(t, Validate(&req))
+	if len(fields) != 2 || fields["body.guests"].Rule != "min" || fields["body.rating"].Rule != "oneof" {
+		t.Errorf("expected min and oneof to reject zero, got %v", fields)
+	}
+
+	req.Guests, req.Rating = 2, 3
+	if err := Validate(&req); err != nil {
+		t.Errorf("expected omitempty fields to be skipped, got %v", err)
+	}
+	req.Age = 12
+	if fields := fieldErrors(t, Validate(&req)); fields["body.age"].Rule != "min" {
+		t.Errorf("expected omitempty field to be checked when set, got %v", fields)
+	}
+}
+
+func TestValidate_UnknownRule(t *testing.T) {
+	req := struct {
+		Name string `validate:"shiny"`
+	}{Name: "x"}
+
+	err := Validate(&req)
+	var httpErr *HTTPError
+	if err == nil || errors.As(err, &httpErr) {
+		t.Errorf("expected a plain programming error, got %v", err)
+	}
+}
+
+func decodeRequest(body string) *http.Request {
+	return httptest.NewRequest("POST", "/", strings.NewReader(body))
+}
+
+func TestDecodeAndValidate(t *testing.T) {
+	var req signupRequest
+	err := DecodeAndValidate(decodeRequest(`{"email":"ada@example.com","name":"Ada","address":{"city":"London"}}`), &req, 0)
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if req.Email != "ada@example.com" {
+		t.Errorf("body not decoded: %+v", req)
+	}
+}
+
+func TestDecodeAndValidate_RejectsUnknownFields(t *testing.T) {
+	var req signupRequest
+	err := DecodeAndValidate(decodeRequest(`{"email":"ada@example.com","admin":true}`), &req, 0)
+
+	fields := fieldErrors(t, err)
+	if fe := fields["body.admin"]; fe.Rule != "unknown" {
+		t.Errorf("expected unknown field error, got %v", fields)
+	}
+}
+
+func TestDecodeAndValidate_RejectsTrailingData(t *testing.T) {
+	var req signupRequest
+	err := DecodeAndValidate(decodeRequest(`{"email":"a@b.co"} {"email":"c@d.co"}`), &req, 0)
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Status != http.StatusBadRequest {
+		t.Errorf("expected 400 for trailing data, got %v", err)
+	}
+}
+
+func TestDecodeAndValidate_EnforcesMaxBytes(t *testing.T) {
+	var req signupRequest
+	body := `{"name":"` + strings.Repeat("a", 100) + `"}`
+	err := DecodeAndValidate(decodeRequest(body), &req, 50)
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Status != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %v", err)
+	}
+}
+
+func TestDecodeAndValidate_ValidationFailureWrites422(t *testing.T) {
+	var req signupRequest
+	err := DecodeAndValidate(decodeRequest(`{"email":"nope"}`), &req, 0)
+
+	w := httptest.NewRecorder()
+	FromError(err).Write(context.Background(), w)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", w.Code)
+	}
+	body := decodeBody(t, w)
+	details, _ := body["details"].([]any)
+	first, _ := details[0].(map[string]any)
+	if first["field"] == nil || first["rule"] == nil || first["message"] == nil {
+		t.Errorf("field errors should include field, rule and message: %v", details)
+	}
+}
+
+func TestTyped_Validates(t *testing.T) {
+	handler := Typed(func(ctx context.Context, req signupRequest) (map[string]string, error) {
+		t.Error("handler should not run for invalid request")
+		return nil, nil
+	})
+
+	w := httptest.NewRecorder()
+	handler(context.Background(), decodeRequest(`{"email":"nope"}`)).Write(context.Background(), w)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422, got %d", w.Code)
+	}
+}